@@ -0,0 +1,180 @@
+package goss
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+const (
+	gossTestsTarball = "goss-tests.tar.gz"
+	gossTestsZip     = "goss-tests.zip"
+)
+
+// archiveName returns the file name of the tests archive for the remote shell
+func (p *Provisioner) archiveName() string {
+	if p.shell() != shellPosix {
+		return gossTestsZip
+	}
+	return gossTestsTarball
+}
+
+// archiveEntries returns the upload plan with remote paths relative to remote_path
+func (p *Provisioner) archiveEntries() ([]upload, error) {
+	plan, err := p.uploadPlan()
+	if err != nil {
+		return nil, err
+	}
+
+	root := strings.TrimSuffix(filepath.ToSlash(p.config.RemotePath), "/") + "/"
+	entries := make([]upload, 0, len(plan))
+	for _, u := range plan {
+		if !strings.HasPrefix(u.dst, root) {
+			return nil, fmt.Errorf("'%s' is not below remote_path '%s'", u.dst, p.config.RemotePath)
+		}
+		entries = append(entries, upload{src: u.src, dst: strings.TrimPrefix(u.dst, root)})
+	}
+	return entries, nil
+}
+
+// writeTarGz writes the entries into a gzip compressed tarball
+func writeTarGz(w io.Writer, entries []upload) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	for _, entry := range entries {
+		info, err := os.Stat(entry.src)
+		if err != nil {
+			return err
+		}
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		header.Name = entry.dst
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if err := copyFile(tw, entry.src); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// writeZip writes the entries into a zip archive
+func writeZip(w io.Writer, entries []upload) error {
+	zw := zip.NewWriter(w)
+
+	for _, entry := range entries {
+		info, err := os.Stat(entry.src)
+		if err != nil {
+			return err
+		}
+		header, err := zip.FileInfoHeader(info)
+		if err != nil {
+			return err
+		}
+		header.Name = entry.dst
+		header.Method = zip.Deflate
+		fw, err := zw.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+		if err := copyFile(fw, entry.src); err != nil {
+			return err
+		}
+	}
+
+	return zw.Close()
+}
+
+func copyFile(w io.Writer, src string) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(w, f)
+	return err
+}
+
+// canExtract checks that the remote host has the tools to extract the tests archive
+func (p *Provisioner) canExtract(ui packer.Ui, comm packer.Communicator) bool {
+	tool := "tar"
+	if p.shell() != shellPosix {
+		tool = "Expand-Archive"
+	}
+
+	cmd := &packer.RemoteCmd{Command: p.dialect().hasCommand(tool)}
+	if err := cmd.RunWithUi(context.TODO(), comm, ui); err != nil {
+		return false
+	}
+	return cmd.ExitStatus() == 0
+}
+
+// extractCmd returns the command extracting the uploaded tests archive into remote_path
+func (p *Provisioner) extractCmd(archive string) string {
+	return p.dialect().extract(archive, p.config.RemotePath)
+}
+
+// uploadArchive packs the tests into one archive, uploads and extracts it.
+// It returns false without uploading anything when the remote host can't extract the archive.
+func (p *Provisioner) uploadArchive(ui packer.Ui, comm packer.Communicator) (bool, error) {
+	if !p.canExtract(ui, comm) {
+		ui.Message("Unable to extract archives on the remote host, uploading tests one by one")
+		return false, nil
+	}
+
+	entries, err := p.archiveEntries()
+	if err != nil {
+		return false, err
+	}
+
+	f, err := os.CreateTemp("", "goss-tests-*")
+	if err != nil {
+		return false, fmt.Errorf("Error creating archive: %s", err)
+	}
+	defer os.Remove(f.Name())
+
+	if p.shell() != shellPosix {
+		err = writeZip(f, entries)
+	} else {
+		err = writeTarGz(f, entries)
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return false, fmt.Errorf("Error creating archive: %s", err)
+	}
+
+	archive := path.Join(filepath.ToSlash(p.config.RemotePath), p.archiveName())
+	ui.Message(fmt.Sprintf("Uploading %d files as %s", len(entries), archive))
+	if err := p.uploadFile(ui, comm, archive, f.Name()); err != nil {
+		return false, err
+	}
+
+	cmd := &packer.RemoteCmd{Command: p.extractCmd(archive)}
+	if err := cmd.RunWithUi(context.TODO(), comm, ui); err != nil {
+		return false, err
+	}
+	if cmd.ExitStatus() != 0 {
+		return false, fmt.Errorf("extracting %s: non-zero exit status", archive)
+	}
+	return true, nil
+}
@@ -0,0 +1,141 @@
+package goss
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestProvisioner_archiveEntries(t *testing.T) {
+	root := writeTree(t,
+		"goss/goss.yaml",
+		"goss/nested/service.yaml",
+		"vars.yaml",
+		"shared/nginx.yaml",
+	)
+	// Included gossfiles may be symlinks, UploadDir follows them too
+	if err := os.Symlink(filepath.Join(root, "shared/nginx.yaml"), filepath.Join(root, "goss/nginx.yaml")); err != nil {
+		t.Fatal(err)
+	}
+
+	p := &Provisioner{
+		config: GossConfig{
+			RemotePath: "/tmp/goss",
+			Tests:      []string{filepath.Join(root, "goss")},
+			VarsFile:   filepath.Join(root, "vars.yaml"),
+		},
+	}
+
+	entries, err := p.archiveEntries()
+	if err != nil {
+		t.Fatalf("Provisioner.archiveEntries() error = %v", err)
+	}
+	var got []string
+	for _, entry := range entries {
+		got = append(got, entry.dst)
+	}
+	want := []string{"vars.yaml", "goss/goss.yaml", "goss/nested/service.yaml", "goss/nginx.yaml"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Provisioner.archiveEntries() = %v, want %v", got, want)
+	}
+}
+
+func TestWriteArchive(t *testing.T) {
+	root := writeTree(t, "goss.yaml", "nested/service.yaml")
+	entries := []upload{
+		{src: filepath.Join(root, "goss.yaml"), dst: "goss.yaml"},
+		{src: filepath.Join(root, "nested/service.yaml"), dst: "nested/service.yaml"},
+	}
+	want := []string{"goss.yaml", "nested/service.yaml"}
+
+	t.Run("tar.gz", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := writeTarGz(&buf, entries); err != nil {
+			t.Fatalf("writeTarGz() error = %v", err)
+		}
+		gz, err := gzip.NewReader(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tr := tar.NewReader(gz)
+		var got []string
+		for {
+			header, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, header.Name)
+		}
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("writeTarGz() entries = %v, want %v", got, want)
+		}
+	})
+
+	t.Run("zip", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := writeZip(&buf, entries); err != nil {
+			t.Fatalf("writeZip() error = %v", err)
+		}
+		zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, f := range zr.File {
+			got = append(got, f.Name)
+		}
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("writeZip() entries = %v, want %v", got, want)
+		}
+	})
+}
+
+func TestProvisioner_extractCmd(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  GossConfig
+		archive string
+		want    string
+	}{
+		{
+			name:    "linux",
+			config:  GossConfig{TargetOs: linux, RemotePath: "/tmp/goss"},
+			archive: "/tmp/goss/goss-tests.tar.gz",
+			want:    "tar -xzf '/tmp/goss/goss-tests.tar.gz' -C '/tmp/goss' && rm -f '/tmp/goss/goss-tests.tar.gz'",
+		},
+		{
+			name:    "darwin",
+			config:  GossConfig{TargetOs: darwin, RemotePath: "/tmp/goss"},
+			archive: "/tmp/goss/goss-tests.tar.gz",
+			want:    "tar -xzf '/tmp/goss/goss-tests.tar.gz' -C '/tmp/goss' && rm -f '/tmp/goss/goss-tests.tar.gz'",
+		},
+		{
+			name:    "windows",
+			config:  GossConfig{TargetOs: windows, RemotePath: "/tmp/goss"},
+			archive: "/tmp/goss/goss-tests.zip",
+			want:    "powershell /c \"Expand-Archive -Force -Path '/tmp/goss/goss-tests.zip' -DestinationPath '/tmp/goss'; Remove-Item '/tmp/goss/goss-tests.zip'\"",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &Provisioner{
+				config: tt.config,
+			}
+			if got := p.extractCmd(tt.archive); got != tt.want {
+				t.Errorf("Provisioner.extractCmd() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
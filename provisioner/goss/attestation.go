@@ -0,0 +1,193 @@
+package goss
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+const (
+	attestationFile = "goss-attestation.intoto.jsonl"
+
+	inTotoStatementType = "https://in-toto.io/Statement/v1"
+	inTotoPayloadType   = "application/vnd.in-toto+json"
+	// gossPredicateType identifies the predicate of the goss validation statement
+	gossPredicateType = "https://github.com/YaleUniversity/packer-plugin-goss/validation/v1"
+)
+
+// inTotoStatement is an in-toto v1 statement about the rendered goss spec
+type inTotoStatement struct {
+	Type          string          `json:"_type"`
+	Subject       []inTotoSubject `json:"subject"`
+	PredicateType string          `json:"predicateType"`
+	Predicate     gossPredicate   `json:"predicate"`
+}
+
+type inTotoSubject struct {
+	Name   string            `json:"name"`
+	Digest map[string]string `json:"digest"`
+}
+
+// gossPredicate describes the goss validate run of a build
+type gossPredicate struct {
+	BuildName   string      `json:"buildName"`
+	BuildType   string      `json:"buildType"`
+	TargetOs    string      `json:"targetOs"`
+	GossVersion string      `json:"gossVersion"`
+	Summary     gossSummary `json:"summary"`
+	ValidatedOn string      `json:"validatedOn"`
+}
+
+type gossSummary struct {
+	TestCount    int `json:"testCount"`
+	FailedCount  int `json:"failedCount"`
+	SkippedCount int `json:"skippedCount"`
+}
+
+// dsseEnvelope is a DSSE envelope signing an in-toto statement
+type dsseEnvelope struct {
+	PayloadType string          `json:"payloadType"`
+	Payload     string          `json:"payload"`
+	Signatures  []dsseSignature `json:"signatures"`
+}
+
+type dsseSignature struct {
+	KeyID string `json:"keyid"`
+	Sig   string `json:"sig"`
+}
+
+// pae is the DSSE pre-authentication encoding of a payload, the signed message
+func pae(payloadType string, payload []byte) []byte {
+	return []byte(fmt.Sprintf("DSSEv1 %d %s %d %s", len(payloadType), payloadType, len(payload), payload))
+}
+
+// readAttestationKey reads an ed25519 private key from a PKCS #8 PEM file,
+// as written by openssl genpkey -algorithm ed25519
+func readAttestationKey(file string) (ed25519.PrivateKey, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(content)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data in %s", file)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	priv, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%s isn't an ed25519 private key", file)
+	}
+	return priv, nil
+}
+
+// attestationKeyID returns the SHA-256 of the DER encoded public key
+func attestationKeyID(pub ed25519.PublicKey) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(der)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// statement returns the in-toto statement about the rendered spec and the validate results
+func (p *Provisioner) statement(spec []byte, now time.Time) inTotoStatement {
+	sum := sha256.Sum256(spec)
+	s := inTotoStatement{
+		Type: inTotoStatementType,
+		Subject: []inTotoSubject{{
+			Name:   filepath.Base(p.specFile()),
+			Digest: map[string]string{"sha256": hex.EncodeToString(sum[:])},
+		}},
+		PredicateType: gossPredicateType,
+		Predicate: gossPredicate{
+			BuildName:   p.config.PackerBuildName,
+			BuildType:   p.config.PackerBuilderType,
+			TargetOs:    strings.ToLower(p.config.TargetOs),
+			GossVersion: p.config.Version,
+			ValidatedOn: now.UTC().Format(time.RFC3339),
+		},
+	}
+	if p.results != nil {
+		s.Predicate.Summary = gossSummary{
+			TestCount:    p.results.Summary.TestCount,
+			FailedCount:  p.results.Summary.FailedCount,
+			SkippedCount: p.results.Summary.SkippedCount,
+		}
+	}
+	return s
+}
+
+// signStatement wraps the statement in a DSSE envelope signed with key
+func signStatement(s inTotoStatement, key ed25519.PrivateKey) (*dsseEnvelope, error) {
+	payload, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+	keyID, err := attestationKeyID(key.Public().(ed25519.PublicKey))
+	if err != nil {
+		return nil, err
+	}
+	return &dsseEnvelope{
+		PayloadType: inTotoPayloadType,
+		Payload:     base64.StdEncoding.EncodeToString(payload),
+		Signatures: []dsseSignature{{
+			KeyID: keyID,
+			Sig:   base64.StdEncoding.EncodeToString(ed25519.Sign(key, pae(inTotoPayloadType, payload))),
+		}},
+	}, nil
+}
+
+// writeAttestation signs a statement about the rendered spec and the validate results
+// with attestation_key and writes it next to the downloaded specs. Only a run whose
+// results show no failed tests is signed, failed tests inspect mode or a warn severity
+// let through aren't attested.
+func (p *Provisioner) writeAttestation(ui packer.Ui, comm packer.Communicator) error {
+	if _, ok := comm.(*dryRunPlan); ok {
+		ui.Message("Dry run: skipping the attestation")
+		return nil
+	}
+	if p.results == nil {
+		ui.Error("Warning: not signing the attestation, goss validate printed no results")
+		return nil
+	}
+	if failed := p.results.Summary.FailedCount; failed != 0 {
+		ui.Error(fmt.Sprintf("Warning: not signing the attestation, %d tests failed", failed))
+		return nil
+	}
+
+	key, err := readAttestationKey(p.config.AttestationKey)
+	if err != nil {
+		return fmt.Errorf("Error reading attestation key: %s", err)
+	}
+	var spec bytes.Buffer
+	if err := comm.Download(p.specFile(), &spec); err != nil {
+		return fmt.Errorf("Error downloading rendered spec: %s", err)
+	}
+
+	envelope, err := signStatement(p.statement(spec.Bytes(), time.Now()), key)
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(envelope)
+	if err != nil {
+		return err
+	}
+	ui.Message(fmt.Sprintf("Writing signed attestation to %s", attestationFile))
+	return os.WriteFile(attestationFile, append(b, '\n'), 0644)
+}
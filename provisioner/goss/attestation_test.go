@@ -0,0 +1,148 @@
+package goss
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/common"
+	"github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// writeAttestationKey writes a new ed25519 key as PKCS #8 PEM file
+func writeAttestationKey(t *testing.T) (string, ed25519.PublicKey) {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(t.TempDir(), "attestation.pem")
+	if err := os.WriteFile(file, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return file, pub
+}
+
+func TestProvisioner_writeAttestation(t *testing.T) {
+	key, pub := writeAttestationKey(t)
+	chdir(t, t.TempDir())
+
+	const spec = "package:\n  nginx:\n    installed: true\n"
+	results, err := parseResults(`{"results":[],"summary":{"failed-count":0,"skipped-count":1,"test-count":12}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &Provisioner{
+		config: GossConfig{
+			PackerConfig:   common.PackerConfig{PackerBuildName: "amazon-ebs.web", PackerBuilderType: "amazon-ebs"},
+			TargetOs:       linux,
+			Version:        "0.4.2",
+			Attestation:    true,
+			AttestationKey: key,
+		},
+		results: results,
+	}
+	comm := &packer.MockCommunicator{DownloadData: spec}
+	if err := p.writeAttestation(packer.TestUi(t), comm); err != nil {
+		t.Fatalf("Provisioner.writeAttestation() error = %v", err)
+	}
+	if comm.DownloadPath != p.specFile() {
+		t.Errorf("downloaded %s, want %s", comm.DownloadPath, p.specFile())
+	}
+
+	b, err := os.ReadFile(attestationFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var envelope dsseEnvelope
+	if err := json.Unmarshal(b, &envelope); err != nil {
+		t.Fatal(err)
+	}
+	payload, err := base64.StdEncoding.DecodeString(envelope.Payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(envelope.Signatures) != 1 {
+		t.Fatalf("signatures = %d, want 1", len(envelope.Signatures))
+	}
+	sig, err := base64.StdEncoding.DecodeString(envelope.Signatures[0].Sig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ed25519.Verify(pub, pae(envelope.PayloadType, payload), sig) {
+		t.Errorf("invalid attestation signature")
+	}
+	if keyID, _ := attestationKeyID(pub); envelope.Signatures[0].KeyID != keyID {
+		t.Errorf("keyid = %s, want %s", envelope.Signatures[0].KeyID, keyID)
+	}
+
+	var statement inTotoStatement
+	if err := json.Unmarshal(payload, &statement); err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256([]byte(spec))
+	if got := statement.Subject[0].Digest["sha256"]; got != hex.EncodeToString(sum[:]) {
+		t.Errorf("subject digest = %s, want %x", got, sum)
+	}
+	want := gossPredicate{
+		BuildName:   "amazon-ebs.web",
+		BuildType:   "amazon-ebs",
+		TargetOs:    "linux",
+		GossVersion: "0.4.2",
+		Summary:     gossSummary{TestCount: 12, SkippedCount: 1},
+		ValidatedOn: statement.Predicate.ValidatedOn,
+	}
+	if statement.Predicate != want {
+		t.Errorf("predicate = %+v, want %+v", statement.Predicate, want)
+	}
+}
+
+func TestProvisioner_writeAttestationFailed(t *testing.T) {
+	key, _ := writeAttestationKey(t)
+
+	tests := []struct {
+		name    string
+		results string
+	}{
+		{
+			name: "no results",
+		},
+		{
+			name:    "failed tests",
+			results: `{"results":[],"summary":{"failed-count":2,"skipped-count":0,"test-count":12}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chdir(t, t.TempDir())
+			p := &Provisioner{
+				config: GossConfig{TargetOs: linux, Attestation: true, AttestationKey: key},
+			}
+			if tt.results != "" {
+				results, err := parseResults(tt.results)
+				if err != nil {
+					t.Fatal(err)
+				}
+				p.results = results
+			}
+			comm := &packer.MockCommunicator{DownloadData: "package: {}\n"}
+			if err := p.writeAttestation(packer.TestUi(t), comm); err != nil {
+				t.Fatalf("Provisioner.writeAttestation() error = %v", err)
+			}
+			if _, err := os.Stat(attestationFile); err == nil {
+				t.Errorf("attestation written for a run that didn't pass")
+			}
+		})
+	}
+}
@@ -0,0 +1,207 @@
+package goss
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"html/template"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+const (
+	complianceReportMd   = "goss-compliance.md"
+	complianceReportHTML = "goss-compliance.html"
+	complianceReportCSV  = "goss-compliance.csv"
+
+	controlPass = "pass"
+	controlFail = "fail"
+	controlSkip = "skip"
+)
+
+// control is a row of the compliance matrix
+type control struct {
+	Benchmark string
+	ID        string
+	Title     string
+	Status    string
+	Resources []string
+}
+
+// metaValues returns the values of the meta key of result, which can be a string or a list
+func metaValues(result gossResult, key string) []string {
+	switch value := result.Meta[key].(type) {
+	case nil:
+		return nil
+	case []interface{}:
+		values := make([]string, 0, len(value))
+		for _, v := range value {
+			values = append(values, fmt.Sprint(v))
+		}
+		return values
+	default:
+		return []string{fmt.Sprint(value)}
+	}
+}
+
+// resultStatus returns pass, fail or skip for a single test result
+func resultStatus(result gossResult) string {
+	switch result.Result {
+	case gossResultFail:
+		return controlFail
+	case gossResultSkip:
+		return controlSkip
+	default:
+		return controlPass
+	}
+}
+
+// complianceMatrix groups the test results by the controls in meta.control.
+// A control fails when any of its tests failed and is skipped when all of them were.
+// It returns the controls ordered by benchmark and ID, and the number of tests without a control.
+func complianceMatrix(results *gossResults) ([]*control, int) {
+	controls := make(map[string]*control)
+	unmapped := 0
+	for _, result := range results.Results {
+		ids := metaValues(result, "control")
+		if len(ids) == 0 {
+			unmapped++
+			continue
+		}
+
+		benchmark := strings.Join(metaValues(result, "benchmark"), ", ")
+		status := resultStatus(result)
+		resource := fmt.Sprintf("%s: %s: %s (%s)", result.ResourceType, result.ResourceID, result.Property, status)
+		for _, id := range ids {
+			key := benchmark + "\x00" + id
+			c, ok := controls[key]
+			if !ok {
+				c = &control{Benchmark: benchmark, ID: id, Status: status}
+				controls[key] = c
+			}
+			if c.Title == "" {
+				c.Title = result.Title
+			}
+			switch {
+			case status == controlFail:
+				c.Status = controlFail
+			case c.Status == controlSkip && status == controlPass:
+				c.Status = controlPass
+			}
+			c.Resources = append(c.Resources, resource)
+		}
+	}
+
+	matrix := make([]*control, 0, len(controls))
+	for _, c := range controls {
+		matrix = append(matrix, c)
+	}
+	sort.Slice(matrix, func(i, j int) bool {
+		if matrix[i].Benchmark != matrix[j].Benchmark {
+			return matrix[i].Benchmark < matrix[j].Benchmark
+		}
+		return matrix[i].ID < matrix[j].ID
+	})
+	return matrix, unmapped
+}
+
+// mdEscape escapes s for a Markdown table cell
+func mdEscape(s string) string {
+	return strings.Replace(strings.Replace(s, "|", "\\|", -1), "\n", " ", -1)
+}
+
+func complianceMarkdown(matrix []*control, unmapped int) []byte {
+	var b bytes.Buffer
+	b.WriteString("# Goss compliance report\n\n")
+	b.WriteString("| Benchmark | Control | Title | Status | Resources |\n")
+	b.WriteString("|---|---|---|---|---|\n")
+	for _, c := range matrix {
+		fmt.Fprintf(&b, "| %s | %s | %s | %s | %s |\n", mdEscape(c.Benchmark), mdEscape(c.ID),
+			mdEscape(c.Title), c.Status, mdEscape(strings.Join(c.Resources, "<br>")))
+	}
+	if unmapped != 0 {
+		fmt.Fprintf(&b, "\n%d tests are not mapped to a control.\n", unmapped)
+	}
+	return b.Bytes()
+}
+
+var complianceHTMLTemplate = template.Must(template.New("compliance").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Goss compliance report</title>
+<style>
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; vertical-align: top; }
+.pass { color: #1a7f37; } .fail { color: #cf222e; } .skip { color: #9a6700; }
+</style>
+</head>
+<body>
+<h1>Goss compliance report</h1>
+<table>
+<tr><th>Benchmark</th><th>Control</th><th>Title</th><th>Status</th><th>Resources</th></tr>
+{{- range .Controls}}
+<tr><td>{{.Benchmark}}</td><td>{{.ID}}</td><td>{{.Title}}</td><td class="{{.Status}}">{{.Status}}</td><td>{{range $i, $r := .Resources}}{{if $i}}<br>{{end}}{{$r}}{{end}}</td></tr>
+{{- end}}
+</table>
+{{- if .Unmapped}}
+<p>{{.Unmapped}} tests are not mapped to a control.</p>
+{{- end}}
+</body>
+</html>
+`))
+
+func complianceHTML(matrix []*control, unmapped int) ([]byte, error) {
+	var b bytes.Buffer
+	err := complianceHTMLTemplate.Execute(&b, struct {
+		Controls []*control
+		Unmapped int
+	}{matrix, unmapped})
+	return b.Bytes(), err
+}
+
+func complianceCSV(matrix []*control) ([]byte, error) {
+	var b bytes.Buffer
+	w := csv.NewWriter(&b)
+	_ = w.Write([]string{"benchmark", "control", "title", "status", "resources"})
+	for _, c := range matrix {
+		_ = w.Write([]string{c.Benchmark, c.ID, c.Title, c.Status, strings.Join(c.Resources, "; ")})
+	}
+	w.Flush()
+	return b.Bytes(), w.Error()
+}
+
+// writeComplianceReports writes the compliance matrix of the validate results
+// as Markdown, HTML and CSV to the current directory, next to the spec files
+func (p *Provisioner) writeComplianceReports(ui packer.Ui, results *gossResults) error {
+	matrix, unmapped := complianceMatrix(results)
+
+	htmlReport, err := complianceHTML(matrix, unmapped)
+	if err != nil {
+		return err
+	}
+	csvReport, err := complianceCSV(matrix)
+	if err != nil {
+		return err
+	}
+
+	reports := []struct {
+		file    string
+		content []byte
+	}{
+		{complianceReportMd, complianceMarkdown(matrix, unmapped)},
+		{complianceReportHTML, htmlReport},
+		{complianceReportCSV, csvReport},
+	}
+	for _, report := range reports {
+		if err := os.WriteFile(report.file, report.content, 0644); err != nil {
+			return err
+		}
+	}
+	ui.Message(fmt.Sprintf("Wrote compliance report of %d controls to %s, %s and %s",
+		len(matrix), complianceReportMd, complianceReportHTML, complianceReportCSV))
+	return nil
+}
@@ -0,0 +1,96 @@
+package goss
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+const complianceResults = `{
+  "results": [
+    {"resource-type": "File", "resource-id": "/etc/ssh/sshd_config", "property": "contains", "title": "Ensure SSH root login is disabled", "meta": {"control": "5.2.10", "benchmark": "CIS"}, "result": 0},
+    {"resource-type": "Service", "resource-id": "sshd", "property": "running", "meta": {"control": "5.2.10", "benchmark": "CIS"}, "result": 1},
+    {"resource-type": "Package", "resource-id": "telnet", "property": "installed", "title": "Ensure telnet client is not installed", "meta": {"control": ["2.3.4", "V-1234"], "benchmark": "CIS"}, "result": 0},
+    {"resource-type": "Mount", "resource-id": "/tmp", "property": "opts", "meta": {"control": "1.1.2", "benchmark": "CIS"}, "result": 2},
+    {"resource-type": "User", "resource-id": "root", "property": "exists", "result": 0}
+  ],
+  "summary": {"failed-count": 1, "test-count": 5}
+}`
+
+func Test_complianceMatrix(t *testing.T) {
+	results, err := parseResults(complianceResults)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	matrix, unmapped := complianceMatrix(results)
+	if unmapped != 1 {
+		t.Errorf("complianceMatrix() unmapped = %v, want 1", unmapped)
+	}
+
+	var got []control
+	for _, c := range matrix {
+		got = append(got, *c)
+	}
+	want := []control{
+		{Benchmark: "CIS", ID: "1.1.2", Status: controlSkip, Resources: []string{"Mount: /tmp: opts (skip)"}},
+		{Benchmark: "CIS", ID: "2.3.4", Title: "Ensure telnet client is not installed", Status: controlPass,
+			Resources: []string{"Package: telnet: installed (pass)"}},
+		{Benchmark: "CIS", ID: "5.2.10", Title: "Ensure SSH root login is disabled", Status: controlFail,
+			Resources: []string{"File: /etc/ssh/sshd_config: contains (pass)", "Service: sshd: running (fail)"}},
+		{Benchmark: "CIS", ID: "V-1234", Title: "Ensure telnet client is not installed", Status: controlPass,
+			Resources: []string{"Package: telnet: installed (pass)"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("complianceMatrix() = %+v, want %+v", got, want)
+	}
+}
+
+func TestProvisioner_writeComplianceReports(t *testing.T) {
+	chdir(t, t.TempDir())
+
+	results, err := parseResults(complianceResults)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &Provisioner{
+		config: GossConfig{ComplianceReport: true},
+	}
+	if err := p.writeComplianceReports(packer.TestUi(t), results); err != nil {
+		t.Fatalf("Provisioner.writeComplianceReports() error = %v", err)
+	}
+
+	tests := []struct {
+		file string
+		want []string
+	}{
+		{
+			file: complianceReportMd,
+			want: []string{"| CIS | 5.2.10 | Ensure SSH root login is disabled | fail |", "1 tests are not mapped to a control."},
+		},
+		{
+			file: complianceReportHTML,
+			want: []string{`<td class="fail">fail</td>`, "Service: sshd: running (fail)"},
+		},
+		{
+			file: complianceReportCSV,
+			want: []string{"benchmark,control,title,status,resources", "CIS,1.1.2,,skip,Mount: /tmp: opts (skip)"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			b, err := os.ReadFile(tt.file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(string(b), want) {
+					t.Errorf("%s doesn't contain %q:\n%s", tt.file, want, b)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package goss
+
+import (
+	"context"
+	"fmt"
+	"path"
+
+	"github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+const (
+	posixTempFolder   = "/tmp"
+	windowsTempFolder = "C:/Windows/Temp"
+	defaultExecFolder = "/var/tmp"
+)
+
+// tempFolder returns the remote folder goss, the tests and the rendered specs
+// are staged in by default
+func (p *Provisioner) tempFolder() string {
+	if p.config.TargetOs == windows {
+		return windowsTempFolder
+	}
+	return posixTempFolder
+}
+
+// remoteFolder returns the configured remote_folder, or the temp folder before Prepare set it
+func (p *Provisioner) remoteFolder() string {
+	if p.config.RemoteFolder == "" {
+		return p.tempFolder()
+	}
+	return p.config.RemoteFolder
+}
+
+func (p *Provisioner) specFile() string {
+	return path.Join(p.remoteFolder(), gossSpecFile)
+}
+
+func (p *Provisioner) debugSpecFile() string {
+	return path.Join(p.remoteFolder(), gossDebugSpecFile)
+}
+
+func (p *Provisioner) execFolder() string {
+	if p.config.ExecFolder == "" {
+		return defaultExecFolder
+	}
+	return p.config.ExecFolder
+}
+
+// execCheckCmd succeeds when files in dir can be executed, i.e. dir isn't mounted noexec
+func execCheckCmd(dir string) string {
+	return fmt.Sprintf(
+		"f=$(mktemp '%s/goss-exec-XXXXXX') && printf '#!/bin/sh\\nexit 0\\n' > \"$f\" && chmod 700 \"$f\" && \"$f\"; rc=$?; rm -f \"$f\"; exit $rc",
+		dir)
+}
+
+// canExec checks that files in dir can be executed on the remote host
+func (p *Provisioner) canExec(ui packer.Ui, comm packer.Communicator, dir string) (bool, error) {
+	cmd := &packer.RemoteCmd{Command: execCheckCmd(dir)}
+	if err := cmd.RunWithUi(context.TODO(), comm, ui); err != nil {
+		return false, err
+	}
+	return cmd.ExitStatus() == 0, nil
+}
+
+// ensureExecutable moves download_path to exec_folder when its directory is
+// mounted noexec, as on many hardened Linux images with a noexec /tmp
+func (p *Provisioner) ensureExecutable(ui packer.Ui, comm packer.Communicator) error {
+	if p.shell() != shellPosix {
+		return nil
+	}
+
+	dir := path.Dir(p.config.DownloadPath)
+	ok, err := p.canExec(ui, comm, dir)
+	if err != nil {
+		return err
+	}
+	if ok {
+		return nil
+	}
+
+	alt := p.execFolder()
+	ui.Message(fmt.Sprintf("%s is mounted noexec, staging Goss in %s", dir, alt))
+	if err := p.createDir(ui, comm, alt); err != nil {
+		return err
+	}
+	if ok, err := p.canExec(ui, comm, alt); err != nil {
+		return err
+	} else if !ok {
+		return fmt.Errorf("Neither %s nor %s allow executing files, set exec_folder to a directory mounted without noexec", dir, alt)
+	}
+
+	p.config.DownloadPath = path.Join(alt, path.Base(p.config.DownloadPath))
+	return nil
+}
@@ -0,0 +1,93 @@
+package goss
+
+import (
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+func TestProvisioner_specFile(t *testing.T) {
+	tests := []struct {
+		name      string
+		config    GossConfig
+		wantSpec  string
+		wantDebug string
+	}{
+		{
+			name:      "linux",
+			config:    GossConfig{TargetOs: linux},
+			wantSpec:  "/tmp/goss-spec.yaml",
+			wantDebug: "/tmp/debug-goss-spec.yaml",
+		},
+		{
+			name:      "windows",
+			config:    GossConfig{TargetOs: windows},
+			wantSpec:  "C:/Windows/Temp/goss-spec.yaml",
+			wantDebug: "C:/Windows/Temp/debug-goss-spec.yaml",
+		},
+		{
+			name:      "remote folder",
+			config:    GossConfig{TargetOs: linux, RemoteFolder: "/var/lib/packer"},
+			wantSpec:  "/var/lib/packer/goss-spec.yaml",
+			wantDebug: "/var/lib/packer/debug-goss-spec.yaml",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &Provisioner{
+				config: tt.config,
+			}
+			if got := p.specFile(); got != tt.wantSpec {
+				t.Errorf("Provisioner.specFile() = %v, want %v", got, tt.wantSpec)
+			}
+			if got := p.debugSpecFile(); got != tt.wantDebug {
+				t.Errorf("Provisioner.debugSpecFile() = %v, want %v", got, tt.wantDebug)
+			}
+		})
+	}
+}
+
+func TestProvisioner_ensureExecutable(t *testing.T) {
+	tests := []struct {
+		name       string
+		config     GossConfig
+		exitStatus int
+		wantPath   string
+		wantErr    bool
+	}{
+		{
+			name:       "executable",
+			config:     GossConfig{TargetOs: linux, DownloadPath: "/tmp/goss"},
+			exitStatus: 0,
+			wantPath:   "/tmp/goss",
+		},
+		{
+			name:       "noexec everywhere",
+			config:     GossConfig{TargetOs: linux, DownloadPath: "/tmp/goss"},
+			exitStatus: 1,
+			wantPath:   "/tmp/goss",
+			wantErr:    true,
+		},
+		{
+			name:       "windows is not checked",
+			config:     GossConfig{TargetOs: windows, DownloadPath: "C:/Windows/Temp/goss.exe"},
+			exitStatus: 1,
+			wantPath:   "C:/Windows/Temp/goss.exe",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			comm := &packer.MockCommunicator{StartExitStatus: tt.exitStatus}
+			p := &Provisioner{
+				config: tt.config,
+			}
+			err := p.ensureExecutable(packer.TestUi(t), comm)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Provisioner.ensureExecutable() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if p.config.DownloadPath != tt.wantPath {
+				t.Errorf("Provisioner.ensureExecutable() download path = %v, want %v", p.config.DownloadPath, tt.wantPath)
+			}
+		})
+	}
+}
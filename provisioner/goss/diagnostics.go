@@ -0,0 +1,105 @@
+package goss
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// diagnosticsDir is the local directory on_failure results are written to,
+// next to the downloaded spec files
+const diagnosticsDir = "goss-diagnostics"
+
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._]+`)
+
+// diagnosticsCommandFile returns the file the output of the i-th on_failure command is written to
+func diagnosticsCommandFile(i int, command string) string {
+	name := strings.Trim(unsafeFileChars.ReplaceAllString(command, "-"), "-")
+	if len(name) > 40 {
+		name = name[:40]
+	}
+	return filepath.Join(diagnosticsDir, "commands", fmt.Sprintf("%02d-%s.log", i+1, name))
+}
+
+// diagnosticsFile returns where the remote file is downloaded to,
+// mirroring its remote path below the files directory. Remote paths are
+// POSIX or Windows paths whatever the local OS, those leaving the files
+// directory are rejected.
+func diagnosticsFile(remote string) (string, error) {
+	rel := strings.Replace(strings.Replace(remote, `\`, "/", -1), ":", "", -1)
+	rel = path.Clean(strings.TrimLeft(rel, "/"))
+	if rel == "." || rel == ".." || strings.HasPrefix(rel, "../") {
+		return "", fmt.Errorf("%s is outside of %s", remote, filepath.Join(diagnosticsDir, "files"))
+	}
+	return filepath.Join(diagnosticsDir, "files", filepath.FromSlash(rel)), nil
+}
+
+// collectDiagnostics runs on_failure_commands and downloads on_failure_files.
+// Failures are reported but don't replace the error of the goss run.
+func (p *Provisioner) collectDiagnostics(ui packer.Ui, comm packer.Communicator) {
+	if len(p.config.OnFailureCommands) == 0 && len(p.config.OnFailureFiles) == 0 {
+		return
+	}
+
+	ui.Say(fmt.Sprintf("Collecting diagnostics into %s", diagnosticsDir))
+	for i, command := range p.config.OnFailureCommands {
+		if err := p.captureCommand(ui, comm, diagnosticsCommandFile(i, command), command); err != nil {
+			ui.Error(fmt.Sprintf("Error collecting output of '%s': %s", command, err))
+		}
+	}
+	for _, remote := range p.config.OnFailureFiles {
+		file, err := diagnosticsFile(remote)
+		if err == nil {
+			err = p.downloadDiagnosticsFile(ui, comm, file, remote)
+		}
+		if err != nil {
+			ui.Error(fmt.Sprintf("Error downloading %s: %s", remote, err))
+		}
+	}
+}
+
+// captureCommand runs command and writes its output and exit status to file
+func (p *Provisioner) captureCommand(ui packer.Ui, comm packer.Communicator, file, command string) error {
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	ui.Message(fmt.Sprintf("Running %s", command))
+	fmt.Fprintf(f, "$ %s\n", command)
+	cmd := &packer.RemoteCmd{Command: command, Stdout: f, Stderr: f}
+	if err := cmd.RunWithUi(context.TODO(), comm, ui); err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(f, "\nexit status %d\n", cmd.ExitStatus())
+	return err
+}
+
+// downloadDiagnosticsFile downloads the remote file to file
+func (p *Provisioner) downloadDiagnosticsFile(ui packer.Ui, comm packer.Communicator, file, remote string) error {
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+
+	ui.Message(fmt.Sprintf("Downloading %s", remote))
+	if err := comm.Download(remote, f); err != nil {
+		_ = f.Close()
+		_ = os.Remove(file)
+		return err
+	}
+	return f.Close()
+}
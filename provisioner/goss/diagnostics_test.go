@@ -0,0 +1,207 @@
+package goss
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// chdir changes into dir for the duration of the test
+func chdir(t *testing.T, dir string) {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.Chdir(wd) })
+}
+
+func Test_diagnosticsCommandFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		command string
+		want    string
+	}{
+		{
+			name:    "simple",
+			command: "journalctl -xe",
+			want:    "goss-diagnostics/commands/01-journalctl-xe.log",
+		},
+		{
+			name:    "pipes and quotes",
+			command: "systemctl status 'nginx' | tail",
+			want:    "goss-diagnostics/commands/01-systemctl-status-nginx-tail.log",
+		},
+		{
+			name:    "long",
+			command: strings.Repeat("a", 50),
+			want:    "goss-diagnostics/commands/01-" + strings.Repeat("a", 40) + ".log",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := filepath.ToSlash(diagnosticsCommandFile(0, tt.command)); got != tt.want {
+				t.Errorf("diagnosticsCommandFile() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_diagnosticsFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		remote  string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:   "linux",
+			remote: "/var/log/messages",
+			want:   "goss-diagnostics/files/var/log/messages",
+		},
+		{
+			name:   "windows",
+			remote: "C:/Windows/Temp/setup.log",
+			want:   "goss-diagnostics/files/C/Windows/Temp/setup.log",
+		},
+		{
+			name:   "windows backslashes",
+			remote: `C:\Windows\Logs\x.log`,
+			want:   "goss-diagnostics/files/C/Windows/Logs/x.log",
+		},
+		{
+			name:   "dot dot inside",
+			remote: "/var/log/../tmp/x",
+			want:   "goss-diagnostics/files/var/tmp/x",
+		},
+		{
+			name:    "dot dot outside",
+			remote:  "/var/log/../../../../etc/x",
+			wantErr: true,
+		},
+		{
+			name:    "windows dot dot outside",
+			remote:  `C:\..\..\x`,
+			wantErr: true,
+		},
+		{
+			name:    "root",
+			remote:  "/",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := diagnosticsFile(tt.remote)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("diagnosticsFile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got = filepath.ToSlash(got); got != tt.want {
+				t.Errorf("diagnosticsFile() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProvisioner_collectDiagnostics(t *testing.T) {
+	chdir(t, t.TempDir())
+
+	p := &Provisioner{
+		config: GossConfig{
+			OnFailureCommands: []string{"journalctl -xe"},
+			OnFailureFiles:    []string{"/var/log/messages"},
+		},
+	}
+	comm := &packer.MockCommunicator{
+		StartStdout:     "nginx failed",
+		StartExitStatus: 3,
+		DownloadData:    "kernel: oops",
+	}
+	p.collectDiagnostics(packer.TestUi(t), comm)
+
+	if comm.StartCmd == nil || comm.StartCmd.Command != "journalctl -xe" {
+		t.Errorf("command = %v, want journalctl -xe", comm.StartCmd)
+	}
+	out, err := os.ReadFile(diagnosticsCommandFile(0, "journalctl -xe"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"$ journalctl -xe", "nginx failed", "exit status 3"} {
+		if !strings.Contains(string(out), want) {
+			t.Errorf("command output %q doesn't contain %q", out, want)
+		}
+	}
+
+	file, err := os.ReadFile(filepath.Join(diagnosticsDir, "files", "var", "log", "messages"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(file) != "kernel: oops" {
+		t.Errorf("downloaded file = %q, want %q", file, "kernel: oops")
+	}
+}
+
+func TestProvisioner_collectDiagnosticsNone(t *testing.T) {
+	chdir(t, t.TempDir())
+
+	p := &Provisioner{}
+	comm := &packer.MockCommunicator{}
+	p.collectDiagnostics(packer.TestUi(t), comm)
+
+	if comm.StartCalled || comm.DownloadCalled {
+		t.Errorf("communicator used without on_failure settings")
+	}
+	if _, err := os.Stat(diagnosticsDir); !os.IsNotExist(err) {
+		t.Errorf("%s created without on_failure settings", diagnosticsDir)
+	}
+}
+
+// validateFailingCommunicator fails goss validate, the other commands succeed
+type validateFailingCommunicator struct {
+	packer.MockCommunicator
+}
+
+func (c *validateFailingCommunicator) Start(ctx context.Context, cmd *packer.RemoteCmd) error {
+	c.StartExitStatus = 0
+	if strings.Contains(cmd.Command, " validate") {
+		c.StartExitStatus = 1
+	}
+	return c.MockCommunicator.Start(ctx, cmd)
+}
+
+func TestProvisioner_ProvisionInspectDiagnostics(t *testing.T) {
+	tests, err := filepath.Abs("../../example/goss/goss.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	chdir(t, t.TempDir())
+
+	p := &Provisioner{}
+	err = p.Prepare(map[string]interface{}{
+		"tests":                  []string{tests},
+		"inspect":                true,
+		"allow_execution_errors": true,
+		"skip_install":           true,
+		"skip_download":          true,
+		"on_failure_files":       []string{"/var/log/messages"},
+	})
+	if err != nil {
+		t.Fatalf("Provisioner.Prepare() error = %v", err)
+	}
+
+	comm := &validateFailingCommunicator{packer.MockCommunicator{DownloadData: "kernel: oops"}}
+	if err := p.Provision(context.TODO(), packer.TestUi(t), comm, map[string]interface{}{}); err != nil {
+		t.Fatalf("Provisioner.Provision() error = %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(diagnosticsDir, "files", "var", "log", "messages")); err != nil {
+		t.Errorf("diagnostics not collected in inspect mode: %v", err)
+	}
+}
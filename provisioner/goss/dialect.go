@@ -0,0 +1,285 @@
+package goss
+
+import (
+	"encoding/base64"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf16"
+)
+
+const (
+	shellPosix      = "sh"
+	shellCmd        = "cmd"
+	shellPowerShell = "powershell"
+)
+
+var validShells = []string{shellPosix, shellCmd, shellPowerShell}
+
+// dialect builds the remote commands for the shell the communicator runs them in
+type dialect interface {
+	// mkDir creates dir and its parents
+	mkDir(dir string) string
+	// envVars sets environment variables for the command following it
+	envVars(vars map[string]string) string
+	// inlineVars passes the JSON encoded vars as --vars-inline argument
+	inlineVars(json string) string
+	// download fetches url to dst with curl, falling back on wget where available
+	download(env, url, dst, curlFlags, wgetFlags string) string
+	// install makes the downloaded binary executable and prints its version
+	install(binary string) string
+	// exec runs binary with args in dir, writing stdout to output if set
+	exec(dir, env string, sudo bool, binary, args, output string) string
+	// hasCommand succeeds when the named command is available
+	hasCommand(name string) string
+	// extract unpacks archive into dir and removes it
+	extract(archive, dir string) string
+	// quote quotes s as a single argument
+	quote(s string) string
+	// remove deletes file, a missing file isn't an error
+	remove(file string) string
+}
+
+// sortedKeys returns the keys of vars in a stable order
+func sortedKeys(vars map[string]string) []string {
+	keys := make([]string, 0, len(vars))
+	for key := range vars {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// posixDialect targets sh compatible shells on Linux and macOS
+type posixDialect struct{}
+
+func (posixDialect) mkDir(dir string) string {
+	return fmt.Sprintf("mkdir -p '%s'", dir)
+}
+
+func (posixDialect) envVars(vars map[string]string) string {
+	var sb strings.Builder
+	for _, key := range sortedKeys(vars) {
+		sb.WriteString(fmt.Sprintf("%s=\"%s\" ", key, vars[key]))
+	}
+	return sb.String()
+}
+
+func (posixDialect) inlineVars(json string) string {
+	return fmt.Sprintf("--vars-inline '%s'", json)
+}
+
+func (posixDialect) download(env, url, dst, curlFlags, wgetFlags string) string {
+	// Fallback on wget if curl failed for any reason (such as not being installed)
+	return fmt.Sprintf("%scurl -sL %s -o %s %s || %swget -q %s -O %s %s",
+		env, curlFlags, dst, url, env, wgetFlags, dst, url)
+}
+
+func (posixDialect) install(binary string) string {
+	return fmt.Sprintf("chmod 555 %s && %s --version", binary, binary)
+}
+
+func (posixDialect) exec(dir, env string, sudo bool, binary, args, output string) string {
+	cmd := fmt.Sprintf("cd %s && ", dir)
+	if sudo {
+		cmd += "sudo "
+	}
+	cmd += fmt.Sprintf("%s%s %s", env, binary, args)
+	if output != "" {
+		cmd += fmt.Sprintf(" > %s", output)
+	}
+	return cmd
+}
+
+func (posixDialect) hasCommand(name string) string {
+	return fmt.Sprintf("command -v %s", name)
+}
+
+func (posixDialect) extract(archive, dir string) string {
+	return fmt.Sprintf("tar -xzf '%s' -C '%s' && rm -f '%s'", archive, dir, archive)
+}
+
+func (posixDialect) quote(s string) string {
+	return shQuote(s)
+}
+
+func (posixDialect) remove(file string) string {
+	return fmt.Sprintf("rm -f %s", shQuote(file))
+}
+
+// cmdDialect targets cmd.exe, e.g. the default shell of OpenSSH on Windows
+type cmdDialect struct{}
+
+func (cmdDialect) mkDir(dir string) string {
+	return fmt.Sprintf("powershell /c mkdir -p '%s'", dir)
+}
+
+func (cmdDialect) envVars(vars map[string]string) string {
+	var sb strings.Builder
+	for _, key := range sortedKeys(vars) {
+		// Windows requires a call to "set" as separate command seperated by && for each env variable
+		sb.WriteString(fmt.Sprintf("set \"%s=%s\" && ", key, vars[key]))
+	}
+	return sb.String()
+}
+
+func (cmdDialect) inlineVars(json string) string {
+	// don't include single quotes around the json string and replace " with ' otherwise the variables are not recognised
+	return fmt.Sprintf("--vars-inline %s", strings.Replace(json, "\"", "'", -1))
+}
+
+func (cmdDialect) download(env, url, dst, curlFlags, wgetFlags string) string {
+	// curl.exe ships with Windows 10 1803 and later, wget is rarely installed
+	return fmt.Sprintf("%scurl -sL %s -o %s %s || wget -q %s -O %s %s",
+		env, curlFlags, dst, url, wgetFlags, dst, url)
+}
+
+func (cmdDialect) install(binary string) string {
+	return fmt.Sprintf("%s --version", binary)
+}
+
+func (cmdDialect) exec(dir, env string, sudo bool, binary, args, output string) string {
+	cmd := fmt.Sprintf("cd /d %s && %s%s %s", dir, env, binary, args)
+	if output != "" {
+		cmd += fmt.Sprintf(" > %s", output)
+	}
+	return cmd
+}
+
+func (cmdDialect) hasCommand(name string) string {
+	return fmt.Sprintf("powershell /c \"Get-Command %s\"", name)
+}
+
+func (cmdDialect) extract(archive, dir string) string {
+	return fmt.Sprintf("powershell /c \"Expand-Archive -Force -Path '%s' -DestinationPath '%s'; Remove-Item '%s'\"",
+		archive, dir, archive)
+}
+
+func (cmdDialect) quote(s string) string {
+	return "\"" + strings.Replace(s, "\"", "\"\"", -1) + "\""
+}
+
+func (cmdDialect) remove(file string) string {
+	return fmt.Sprintf("powershell /c \"if (Test-Path -LiteralPath '%s') { Remove-Item -Force -LiteralPath '%s' }\"",
+		file, file)
+}
+
+// powerShellDialect targets PowerShell, e.g. when connecting over WinRM.
+// Scripts are passed base64 encoded so they survive whatever shell
+// the communicator starts them from.
+type powerShellDialect struct{}
+
+// psQuote quotes s as a single quoted PowerShell string
+func psQuote(s string) string {
+	return "'" + strings.Replace(s, "'", "''", -1) + "'"
+}
+
+// encodePowerShell wraps script into a powershell invocation using -EncodedCommand
+func encodePowerShell(script string) string {
+	encoded := utf16.Encode([]rune(script))
+	b := make([]byte, 0, len(encoded)*2)
+	for _, c := range encoded {
+		b = append(b, byte(c), byte(c>>8))
+	}
+	return fmt.Sprintf("powershell -NoProfile -NonInteractive -ExecutionPolicy Bypass -EncodedCommand %s",
+		base64.StdEncoding.EncodeToString(b))
+}
+
+// decodedScript returns the script of a command built by encodePowerShell
+func decodedScript(cmd string) (string, bool) {
+	fields := strings.Fields(cmd)
+	if len(fields) < 2 || fields[len(fields)-2] != "-EncodedCommand" {
+		return "", false
+	}
+	b, err := base64.StdEncoding.DecodeString(fields[len(fields)-1])
+	if err != nil {
+		return "", false
+	}
+	u := make([]uint16, 0, len(b)/2)
+	for i := 0; i+1 < len(b); i += 2 {
+		u = append(u, uint16(b[i])|uint16(b[i+1])<<8)
+	}
+	return string(utf16.Decode(u)), true
+}
+
+func (powerShellDialect) mkDir(dir string) string {
+	return encodePowerShell(fmt.Sprintf("New-Item -ItemType Directory -Force -Path %s | Out-Null", psQuote(dir)))
+}
+
+func (powerShellDialect) envVars(vars map[string]string) string {
+	var sb strings.Builder
+	for _, key := range sortedKeys(vars) {
+		sb.WriteString(fmt.Sprintf("$env:%s = %s; ", key, psQuote(vars[key])))
+	}
+	return sb.String()
+}
+
+func (powerShellDialect) inlineVars(json string) string {
+	// PowerShell drops embedded double quotes when calling native executables,
+	// goss reads the single quoted form as YAML just as well. A single quoted
+	// argument keeps PowerShell from expanding $ in the values.
+	return fmt.Sprintf("--vars-inline %s", psQuote(strings.Replace(json, "\"", "'", -1)))
+}
+
+func (powerShellDialect) download(env, url, dst, curlFlags, wgetFlags string) string {
+	// Call curl.exe explicitly, curl is an alias of Invoke-WebRequest in Windows PowerShell.
+	// There's no fallback on Invoke-WebRequest, it can't honour ca_bundle.
+	return encodePowerShell(fmt.Sprintf("%scurl.exe -sL %s -o %s %s; exit $LASTEXITCODE",
+		env, curlFlags, psQuote(dst), psQuote(url)))
+}
+
+func (powerShellDialect) install(binary string) string {
+	return encodePowerShell(fmt.Sprintf("& %s --version; exit $LASTEXITCODE", psQuote(binary)))
+}
+
+func (powerShellDialect) exec(dir, env string, sudo bool, binary, args, output string) string {
+	script := fmt.Sprintf("Set-Location -Path %s; %s& %s %s", psQuote(dir), env, psQuote(binary), args)
+	if output != "" {
+		script += fmt.Sprintf(" | Out-File -Encoding ascii -FilePath %s", psQuote(output))
+	}
+	return encodePowerShell(script + "; exit $LASTEXITCODE")
+}
+
+func (powerShellDialect) hasCommand(name string) string {
+	return encodePowerShell(fmt.Sprintf("if (-not (Get-Command %s -ErrorAction SilentlyContinue)) { exit 1 }", name))
+}
+
+func (powerShellDialect) extract(archive, dir string) string {
+	return encodePowerShell(fmt.Sprintf("Expand-Archive -Force -Path %s -DestinationPath %s; Remove-Item %s",
+		psQuote(archive), psQuote(dir), psQuote(archive)))
+}
+
+func (powerShellDialect) quote(s string) string {
+	return psQuote(s)
+}
+
+func (powerShellDialect) remove(file string) string {
+	return encodePowerShell(fmt.Sprintf("if (Test-Path -LiteralPath %s) { Remove-Item -Force -LiteralPath %s }",
+		psQuote(file), psQuote(file)))
+}
+
+// shell returns the configured shell, or infers it from the target OS and communicator
+func (p *Provisioner) shell() string {
+	if p.config.Shell != "" {
+		return p.config.Shell
+	}
+	if p.config.TargetOs != windows {
+		return shellPosix
+	}
+	if p.connType == "winrm" {
+		return shellPowerShell
+	}
+	return shellCmd
+}
+
+// dialect returns the dialect remote commands are built with
+func (p *Provisioner) dialect() dialect {
+	switch p.shell() {
+	case shellCmd:
+		return cmdDialect{}
+	case shellPowerShell:
+		return powerShellDialect{}
+	default:
+		return posixDialect{}
+	}
+}
@@ -0,0 +1,275 @@
+package goss
+
+import (
+	"strings"
+	"testing"
+)
+
+// decodePowerShell returns the script of a command built by encodePowerShell
+func decodePowerShell(t *testing.T, cmd string) string {
+	t.Helper()
+	script, ok := decodedScript(cmd)
+	if !ok {
+		t.Fatalf("not an encoded powershell command: %s", cmd)
+	}
+	return script
+}
+
+func TestProvisioner_shell(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   GossConfig
+		connType string
+		want     string
+	}{
+		{
+			name:   "linux",
+			config: GossConfig{TargetOs: linux},
+			want:   shellPosix,
+		},
+		{
+			name:   "darwin",
+			config: GossConfig{TargetOs: darwin},
+			want:   shellPosix,
+		},
+		{
+			name:     "windows over ssh",
+			config:   GossConfig{TargetOs: windows},
+			connType: "ssh",
+			want:     shellCmd,
+		},
+		{
+			name:     "windows over winrm",
+			config:   GossConfig{TargetOs: windows},
+			connType: "winrm",
+			want:     shellPowerShell,
+		},
+		{
+			name:     "configured",
+			config:   GossConfig{TargetOs: windows, Shell: shellCmd},
+			connType: "winrm",
+			want:     shellCmd,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &Provisioner{
+				config:   tt.config,
+				connType: tt.connType,
+			}
+			if got := p.shell(); got != tt.want {
+				t.Errorf("Provisioner.shell() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDialect_exec(t *testing.T) {
+	env := map[string]string{"B": "2", "A": "1"}
+
+	tests := []struct {
+		name    string
+		dialect dialect
+		sudo    bool
+		output  string
+		want    string
+	}{
+		{
+			name:    "posix",
+			dialect: posixDialect{},
+			sudo:    true,
+			want:    "cd /tmp/goss && sudo A=\"1\" B=\"2\" /tmp/goss-bin validate",
+		},
+		{
+			name:    "posix with output",
+			dialect: posixDialect{},
+			output:  "/tmp/goss-spec.yaml",
+			want:    "cd /tmp/goss && A=\"1\" B=\"2\" /tmp/goss-bin validate > /tmp/goss-spec.yaml",
+		},
+		{
+			name:    "cmd",
+			dialect: cmdDialect{},
+			sudo:    true,
+			want:    "cd /d /tmp/goss && set \"A=1\" && set \"B=2\" && /tmp/goss-bin validate",
+		},
+		{
+			name:    "powershell",
+			dialect: powerShellDialect{},
+			output:  "/tmp/goss-spec.yaml",
+			want:    "Set-Location -Path '/tmp/goss'; $env:A = '1'; $env:B = '2'; & '/tmp/goss-bin' validate | Out-File -Encoding ascii -FilePath '/tmp/goss-spec.yaml'; exit $LASTEXITCODE",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.dialect.exec("/tmp/goss", tt.dialect.envVars(env), tt.sudo, "/tmp/goss-bin", "validate", tt.output)
+			if _, ok := tt.dialect.(powerShellDialect); ok {
+				got = decodePowerShell(t, got)
+			}
+			if got != tt.want {
+				t.Errorf("dialect.exec() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDialect_install(t *testing.T) {
+	tests := []struct {
+		name    string
+		dialect dialect
+		want    string
+	}{
+		{
+			name:    "posix",
+			dialect: posixDialect{},
+			want:    "chmod 555 /tmp/goss-bin && /tmp/goss-bin --version",
+		},
+		{
+			name:    "cmd",
+			dialect: cmdDialect{},
+			want:    "/tmp/goss-bin --version",
+		},
+		{
+			name:    "powershell",
+			dialect: powerShellDialect{},
+			want:    "& '/tmp/goss-bin' --version; exit $LASTEXITCODE",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.dialect.install("/tmp/goss-bin")
+			if _, ok := tt.dialect.(powerShellDialect); ok {
+				got = decodePowerShell(t, got)
+			}
+			if got != tt.want {
+				t.Errorf("dialect.install() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProvisioner_inline_vars(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   GossConfig
+		connType string
+		want     string
+	}{
+		{
+			name:   "linux",
+			config: GossConfig{TargetOs: linux},
+			want:   "--vars-inline '{\"OS\":\"centos\"}'",
+		},
+		{
+			name:   "windows cmd",
+			config: GossConfig{TargetOs: windows},
+			want:   "--vars-inline {'OS':'centos'}",
+		},
+		{
+			name:     "windows powershell",
+			config:   GossConfig{TargetOs: windows},
+			connType: "winrm",
+			want:     "--vars-inline '{''OS'':''centos''}'",
+		},
+		{
+			name:     "windows powershell with dollar",
+			config:   GossConfig{TargetOs: windows, VarsInline: map[string]string{"PASS": "$ecret"}},
+			connType: "winrm",
+			want:     "--vars-inline '{''PASS'':''$ecret''}'",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.config.VarsInline == nil {
+				tt.config.VarsInline = map[string]string{"OS": "centos"}
+			}
+			p := &Provisioner{
+				config:   tt.config,
+				connType: tt.connType,
+			}
+			if got := p.inline_vars(); got != tt.want {
+				t.Errorf("Provisioner.inline_vars() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDialect_quote(t *testing.T) {
+	tests := []struct {
+		name    string
+		dialect dialect
+		s       string
+		want    string
+	}{
+		{
+			name:    "posix",
+			dialect: posixDialect{},
+			s:       "it's",
+			want:    `'it'\''s'`,
+		},
+		{
+			name:    "cmd",
+			dialect: cmdDialect{},
+			s:       `say "hi"`,
+			want:    `"say ""hi"""`,
+		},
+		{
+			name:    "powershell",
+			dialect: powerShellDialect{},
+			s:       "it's",
+			want:    "'it''s'",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.dialect.quote(tt.s); got != tt.want {
+				t.Errorf("dialect.quote() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDialect_downloadPowerShell(t *testing.T) {
+	got := decodePowerShell(t, powerShellDialect{}.download("$env:HTTPS_PROXY = 'http://proxy:3128'; ",
+		"https://example.com/goss.exe", "C:/goss.exe", "--cacert 'C:/ca.pem'", ""))
+	for _, want := range []string{
+		"$env:HTTPS_PROXY = 'http://proxy:3128'; curl.exe -sL --cacert 'C:/ca.pem' -o 'C:/goss.exe' 'https://example.com/goss.exe'; exit $LASTEXITCODE",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("powerShellDialect.download() = %v, want it to contain %v", got, want)
+		}
+	}
+	if strings.Contains(got, "Invoke-WebRequest") {
+		t.Errorf("powerShellDialect.download() = %v, falls back on Invoke-WebRequest", got)
+	}
+}
+
+func TestDialect_remove(t *testing.T) {
+	tests := []struct {
+		name    string
+		dialect dialect
+		want    string
+	}{
+		{
+			name:    "posix",
+			dialect: posixDialect{},
+			want:    "rm -f '/tmp/goss/goss.yaml'",
+		},
+		{
+			name:    "cmd",
+			dialect: cmdDialect{},
+			want:    `powershell /c "if (Test-Path -LiteralPath '/tmp/goss/goss.yaml') { Remove-Item -Force -LiteralPath '/tmp/goss/goss.yaml' }"`,
+		},
+		{
+			name:    "powershell",
+			dialect: powerShellDialect{},
+			want:    encodePowerShell("if (Test-Path -LiteralPath '/tmp/goss/goss.yaml') { Remove-Item -Force -LiteralPath '/tmp/goss/goss.yaml' }"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.dialect.remove("/tmp/goss/goss.yaml"); got != tt.want {
+				t.Errorf("dialect.remove() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
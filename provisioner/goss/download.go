@@ -0,0 +1,56 @@
+package goss
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
+)
+
+// urlData is the data the url template is rendered with
+type urlData struct {
+	Version string
+	OS      string
+	Arch    string
+	Ext     string
+}
+
+// binaryExt returns the file extension of the goss binary for the target OS
+func (p *Provisioner) binaryExt() string {
+	if p.config.TargetOs == windows {
+		return ".exe"
+	}
+	return ""
+}
+
+// renderURL renders the configured url template and checks the result is a download URL
+func (p *Provisioner) renderURL() (string, error) {
+	ctx := p.config.ctx
+	ctx.Data = &urlData{
+		Version: p.config.Version,
+		OS:      strings.ToLower(p.config.TargetOs),
+		Arch:    p.config.Arch,
+		Ext:     p.binaryExt(),
+	}
+
+	rendered, err := interpolate.Render(p.config.URL, &ctx)
+	if err != nil {
+		return "", fmt.Errorf("Invalid url template %s: %s", p.config.URL, err)
+	}
+
+	u, err := url.Parse(rendered)
+	if err != nil {
+		return "", fmt.Errorf("Invalid url %s: %s", rendered, err)
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return "", fmt.Errorf("Invalid url %s: must be an absolute URL like https://mirror.example.com/goss-{{.OS}}-{{.Arch}}{{.Ext}}", rendered)
+	}
+	return rendered, nil
+}
+
+// defaultDownloadPath returns where goss is downloaded to on the remote host
+func (p *Provisioner) defaultDownloadPath() string {
+	return fmt.Sprintf("%s/goss-%s-%s-%s%s", p.remoteFolder(), p.config.Version,
+		strings.ToLower(p.config.TargetOs), p.config.Arch, p.binaryExt())
+}
@@ -0,0 +1,182 @@
+package goss
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/packer-plugin-sdk/packer"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	driftFail = "fail"
+	driftWarn = "warn"
+)
+
+var validDriftActions = []string{driftFail, driftWarn}
+
+// specDrift are the resources of the rendered spec that differ from the baseline,
+// keyed by resource type and name, e.g. service/sshd
+type specDrift struct {
+	Added   []string
+	Removed []string
+	// Changed resources with the attributes that differ
+	Changed map[string][]string
+}
+
+func (d *specDrift) empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// onDrift returns what happens when the rendered spec drifted from baseline_spec
+func (p *Provisioner) onDrift() string {
+	if p.config.OnDrift == "" {
+		return driftFail
+	}
+	return p.config.OnDrift
+}
+
+// specResources flattens a goss spec to its resources keyed by type/name
+func specResources(content []byte) (map[string]interface{}, error) {
+	var spec map[string]interface{}
+	if err := yaml.Unmarshal(content, &spec); err != nil {
+		return nil, err
+	}
+
+	resources := make(map[string]interface{})
+	for kind, value := range spec {
+		entries, ok := value.(map[string]interface{})
+		if !ok {
+			resources[kind] = value
+			continue
+		}
+		for name, attrs := range entries {
+			resources[kind+"/"+name] = attrs
+		}
+	}
+	return resources, nil
+}
+
+// diffSpecs compares the rendered spec against the baseline by their YAML structure,
+// formatting and the order of keys don't matter
+func diffSpecs(baseline, rendered []byte) (*specDrift, error) {
+	before, err := specResources(baseline)
+	if err != nil {
+		return nil, fmt.Errorf("Error parsing baseline spec: %s", err)
+	}
+	after, err := specResources(rendered)
+	if err != nil {
+		return nil, fmt.Errorf("Error parsing rendered spec: %s", err)
+	}
+
+	drift := &specDrift{Changed: make(map[string][]string)}
+	for key, attrs := range after {
+		old, ok := before[key]
+		if !ok {
+			drift.Added = append(drift.Added, key)
+		} else if !reflect.DeepEqual(old, attrs) {
+			drift.Changed[key] = changedAttributes(old, attrs)
+		}
+	}
+	for key := range before {
+		if _, ok := after[key]; !ok {
+			drift.Removed = append(drift.Removed, key)
+		}
+	}
+	sort.Strings(drift.Added)
+	sort.Strings(drift.Removed)
+	return drift, nil
+}
+
+// changedAttributes returns the attributes differing between two versions of a resource
+func changedAttributes(old, new interface{}) []string {
+	oldAttrs, ok := old.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	newAttrs, ok := new.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	var changed []string
+	for attr, value := range newAttrs {
+		if !reflect.DeepEqual(oldAttrs[attr], value) {
+			changed = append(changed, attr)
+		}
+	}
+	for attr := range oldAttrs {
+		if _, ok := newAttrs[attr]; !ok {
+			changed = append(changed, attr)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+// checkDrift downloads the rendered spec and compares it against baseline_spec.
+// A drifted spec is saved to the current directory for review.
+func (p *Provisioner) checkDrift(ui packer.Ui, comm packer.Communicator) error {
+	ui.Say(fmt.Sprintf("Comparing rendered spec against baseline %s", p.config.BaselineSpec))
+	baseline, err := os.ReadFile(p.config.BaselineSpec)
+	if err != nil {
+		return fmt.Errorf("Error reading baseline spec: %s", err)
+	}
+
+	var rendered bytes.Buffer
+	if err := comm.Download(p.specFile(), &rendered); err != nil {
+		return fmt.Errorf("Error downloading rendered spec: %s", err)
+	}
+	if _, ok := comm.(*dryRunPlan); ok {
+		ui.Message("Dry run: skipping the comparison")
+		return nil
+	}
+
+	drift, err := diffSpecs(baseline, rendered.Bytes())
+	if err != nil {
+		return err
+	}
+	if drift.empty() {
+		ui.Message("Rendered spec matches the baseline")
+		return nil
+	}
+
+	ui.Say(fmt.Sprintf("Rendered spec drifted from the baseline: %d added, %d removed, %d changed",
+		len(drift.Added), len(drift.Removed), len(drift.Changed)))
+	for _, key := range drift.Added {
+		ui.Message(fmt.Sprintf("+ %s", key))
+	}
+	for _, key := range drift.Removed {
+		ui.Message(fmt.Sprintf("- %s", key))
+	}
+	for _, key := range sortedDriftKeys(drift.Changed) {
+		ui.Message(fmt.Sprintf("~ %s: %s", key, strings.Join(drift.Changed[key], ", ")))
+	}
+
+	local := filepath.Base(p.specFile())
+	if err := os.WriteFile(local, rendered.Bytes(), 0644); err != nil {
+		ui.Error(fmt.Sprintf("Error saving rendered spec: %s", err))
+	} else {
+		ui.Message(fmt.Sprintf("Rendered spec saved to %s, review it before updating the baseline", local))
+	}
+
+	if p.onDrift() == driftWarn {
+		ui.Error("Warning: rendered spec drifted from the baseline")
+		return nil
+	}
+	return fmt.Errorf("rendered spec drifted from baseline %s", p.config.BaselineSpec)
+}
+
+func sortedDriftKeys(changed map[string][]string) []string {
+	keys := make([]string, 0, len(changed))
+	for key := range changed {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
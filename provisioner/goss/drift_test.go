@@ -0,0 +1,160 @@
+package goss
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+const baselineSpec = `package:
+  nginx:
+    installed: true
+    versions:
+    - 1.24.0
+service:
+  nginx:
+    enabled: true
+    running: true
+port:
+  tcp:80:
+    listening: true
+`
+
+func Test_diffSpecs(t *testing.T) {
+	tests := []struct {
+		name     string
+		rendered string
+		want     *specDrift
+	}{
+		{
+			name: "reordered",
+			rendered: `port:
+  tcp:80: {listening: true}
+service:
+  nginx: {running: true, enabled: true}
+package:
+  nginx: {installed: true, versions: [1.24.0]}
+`,
+			want: &specDrift{Changed: map[string][]string{}},
+		},
+		{
+			name: "drifted",
+			rendered: `package:
+  nginx:
+    installed: true
+    versions:
+    - 1.25.3
+service:
+  nginx:
+    enabled: true
+user:
+  www-data:
+    exists: true
+`,
+			want: &specDrift{
+				Added:   []string{"user/www-data"},
+				Removed: []string{"port/tcp:80"},
+				Changed: map[string][]string{
+					"package/nginx": {"versions"},
+					"service/nginx": {"running"},
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := diffSpecs([]byte(baselineSpec), []byte(tt.rendered))
+			if err != nil {
+				t.Fatalf("diffSpecs() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("diffSpecs() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProvisioner_checkDrift(t *testing.T) {
+	baseline := filepath.Join(t.TempDir(), "baseline.yaml")
+	if err := os.WriteFile(baseline, []byte(baselineSpec), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		onDrift   string
+		rendered  string
+		wantErr   bool
+		wantSaved bool
+	}{
+		{
+			name:     "no drift",
+			rendered: baselineSpec,
+		},
+		{
+			name:      "fail",
+			rendered:  "package:\n  nginx:\n    installed: false\n",
+			wantErr:   true,
+			wantSaved: true,
+		},
+		{
+			name:      "warn",
+			onDrift:   driftWarn,
+			rendered:  "package:\n  nginx:\n    installed: false\n",
+			wantSaved: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chdir(t, t.TempDir())
+			p := &Provisioner{
+				config: GossConfig{
+					TargetOs:     linux,
+					RemotePath:   "/tmp/goss",
+					BaselineSpec: baseline,
+					OnDrift:      tt.onDrift,
+				},
+			}
+			comm := &packer.MockCommunicator{DownloadData: tt.rendered}
+			if err := p.checkDrift(packer.TestUi(t), comm); (err != nil) != tt.wantErr {
+				t.Errorf("Provisioner.checkDrift() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if comm.DownloadPath != p.specFile() {
+				t.Errorf("downloaded %s, want %s", comm.DownloadPath, p.specFile())
+			}
+			if _, err := os.Stat(gossSpecFile); (err == nil) != tt.wantSaved {
+				t.Errorf("rendered spec saved = %v, want %v", err == nil, tt.wantSaved)
+			}
+		})
+	}
+}
+
+func TestProvisioner_runGossFailedRender(t *testing.T) {
+	baseline := filepath.Join(t.TempDir(), "baseline.yaml")
+	if err := os.WriteFile(baseline, []byte(baselineSpec), 0644); err != nil {
+		t.Fatal(err)
+	}
+	chdir(t, t.TempDir())
+	p := &Provisioner{
+		config: GossConfig{
+			TargetOs:             linux,
+			RemotePath:           "/tmp/goss",
+			BaselineSpec:         baseline,
+			Inspect:              true,
+			AllowExecutionErrors: true,
+		},
+	}
+	comm := &packer.MockCommunicator{
+		StartStderr:     "Error: could not read json data in goss.yaml",
+		StartExitStatus: 1,
+	}
+	if err := p.runGoss(packer.TestUi(t), comm); err != nil {
+		t.Fatalf("Provisioner.runGoss() error = %v", err)
+	}
+	if comm.DownloadCalled {
+		t.Errorf("drift check ran on a failed render")
+	}
+}
@@ -0,0 +1,129 @@
+package goss
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+const redacted = "<sensitive>"
+
+// planStep is one remote operation of a dry run
+type planStep struct {
+	Action      string `json:"action"`
+	Command     string `json:"command,omitempty"`
+	Script      string `json:"script,omitempty"`
+	Source      string `json:"source,omitempty"`
+	Destination string `json:"destination,omitempty"`
+}
+
+// dryRunPlan is a packer.Communicator recording the remote operations
+// instead of running them. Every command succeeds, so checks like the
+// noexec check of download_path are assumed to pass.
+type dryRunPlan struct {
+	ui      packer.Ui
+	secrets []string
+	Steps   []planStep `json:"steps"`
+}
+
+// dryRun reports whether Provision only prints the remote plan
+func (p *Provisioner) dryRun() bool {
+	if p.config.DryRun {
+		return true
+	}
+	enabled, _ := strconv.ParseBool(os.Getenv("PACKER_GOSS_DRY_RUN"))
+	return enabled
+}
+
+// secrets returns the configured passwords, which are masked in plans and scripts
+func (p *Provisioner) secrets() []string {
+	var secrets []string
+	for _, secret := range []string{p.config.Password, p.config.ElevatedPassword} {
+		if secret != "" {
+			secrets = append(secrets, secret)
+		}
+	}
+	return secrets
+}
+
+// redact replaces the secrets in s
+func redact(s string, secrets []string) string {
+	for _, secret := range secrets {
+		s = strings.Replace(s, secret, redacted, -1)
+	}
+	return s
+}
+
+// newDryRunPlan returns a plan printing its steps to ui as they are recorded
+func (p *Provisioner) newDryRunPlan(ui packer.Ui) *dryRunPlan {
+	return &dryRunPlan{ui: ui, secrets: p.secrets()}
+}
+
+func (d *dryRunPlan) record(step planStep) {
+	step.Command = redact(step.Command, d.secrets)
+	step.Script = redact(step.Script, d.secrets)
+	d.Steps = append(d.Steps, step)
+
+	switch {
+	case step.Script != "":
+		d.ui.Message(fmt.Sprintf("[dry run] %s: %s", step.Action, step.Script))
+	case step.Command != "":
+		d.ui.Message(fmt.Sprintf("[dry run] %s: %s", step.Action, step.Command))
+	default:
+		d.ui.Message(fmt.Sprintf("[dry run] %s: %s -> %s", step.Action, step.Source, step.Destination))
+	}
+}
+
+func (d *dryRunPlan) Start(ctx context.Context, cmd *packer.RemoteCmd) error {
+	step := planStep{Action: "run", Command: cmd.Command}
+	if script, ok := decodedScript(cmd.Command); ok {
+		step.Script = script
+	}
+	d.record(step)
+	cmd.SetExited(0)
+	return nil
+}
+
+func (d *dryRunPlan) Upload(dst string, r io.Reader, fi *os.FileInfo) error {
+	// Generated content like the elevated runner scripts has no local source
+	src := "(generated)"
+	if f, ok := r.(*os.File); ok {
+		src = f.Name()
+	}
+	d.record(planStep{Action: "upload", Source: src, Destination: dst})
+	return nil
+}
+
+func (d *dryRunPlan) UploadDir(dst string, src string, exclude []string) error {
+	d.record(planStep{Action: "upload dir", Source: src, Destination: dst})
+	return nil
+}
+
+func (d *dryRunPlan) Download(src string, w io.Writer) error {
+	dst := ""
+	if f, ok := w.(*os.File); ok {
+		dst = f.Name()
+	}
+	d.record(planStep{Action: "download", Source: src, Destination: dst})
+	return nil
+}
+
+func (d *dryRunPlan) DownloadDir(src string, dst string, exclude []string) error {
+	d.record(planStep{Action: "download dir", Source: src, Destination: dst})
+	return nil
+}
+
+// writeJSON writes the recorded plan as JSON to file
+func (d *dryRunPlan) writeJSON(file string) error {
+	b, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(file, append(b, '\n'), 0644)
+}
@@ -0,0 +1,168 @@
+package goss
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+func TestProvisioner_dryRun(t *testing.T) {
+	tests := []struct {
+		name   string
+		config GossConfig
+		env    string
+		want   bool
+	}{
+		{
+			name:   "disabled",
+			config: GossConfig{},
+			want:   false,
+		},
+		{
+			name:   "configured",
+			config: GossConfig{DryRun: true},
+			want:   true,
+		},
+		{
+			name:   "environment",
+			config: GossConfig{},
+			env:    "true",
+			want:   true,
+		},
+		{
+			name:   "invalid environment",
+			config: GossConfig{},
+			env:    "maybe",
+			want:   false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("PACKER_GOSS_DRY_RUN", tt.env)
+			p := &Provisioner{
+				config: tt.config,
+			}
+			if got := p.dryRun(); got != tt.want {
+				t.Errorf("Provisioner.dryRun() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProvisioner_ProvisionDryRun(t *testing.T) {
+	planFile := filepath.Join(t.TempDir(), "plan.json")
+	p := &Provisioner{}
+	err := p.Prepare(map[string]interface{}{
+		"tests":        []string{"../../example/goss/goss.yaml"},
+		"username":     "mirror",
+		"password":     "s3cret",
+		"dry_run":      true,
+		"dry_run_file": planFile,
+	})
+	if err != nil {
+		t.Fatalf("Provisioner.Prepare() error = %v", err)
+	}
+
+	comm := &packer.MockCommunicator{}
+	if err := p.Provision(context.TODO(), packer.TestUi(t), comm, map[string]interface{}{}); err != nil {
+		t.Fatalf("Provisioner.Provision() error = %v", err)
+	}
+	if comm.StartCalled || comm.UploadCalled || comm.DownloadCalled {
+		t.Errorf("dry run used the communicator")
+	}
+
+	b, err := os.ReadFile(planFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(b), "s3cret") {
+		t.Errorf("plan contains the password: %s", b)
+	}
+
+	var plan dryRunPlan
+	if err := json.Unmarshal(b, &plan); err != nil {
+		t.Fatal(err)
+	}
+	var actions []string
+	for _, step := range plan.Steps {
+		actions = append(actions, step.Action)
+	}
+	want := []string{
+		"run",      // mkdir remote_path
+		"run",      // noexec check
+		"run",      // download
+		"run",      // install
+		"upload",   // goss.yaml
+		"run",      // render
+		"run",      // render debug
+		"run",      // validate
+		"download", // spec file
+		"download", // debug spec file
+	}
+	if strings.Join(actions, ",") != strings.Join(want, ",") {
+		t.Errorf("plan actions = %v, want %v", actions, want)
+	}
+	if upload := plan.Steps[4]; upload.Source != "../../example/goss/goss.yaml" || upload.Destination != "/tmp/goss/goss.yaml" {
+		t.Errorf("upload = %+v", upload)
+	}
+	if validate := plan.Steps[7].Command; !strings.Contains(validate, "validate") {
+		t.Errorf("validate command = %s", validate)
+	}
+}
+
+func TestProvisioner_ProvisionDryRunError(t *testing.T) {
+	dir := t.TempDir()
+	planFile := filepath.Join(dir, "plan.json")
+	spec := filepath.Join(dir, "goss.yaml")
+	if err := os.WriteFile(spec, []byte("file: {}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	p := &Provisioner{}
+	err := p.Prepare(map[string]interface{}{
+		"tests":        []string{spec},
+		"dry_run":      true,
+		"dry_run_file": planFile,
+	})
+	if err != nil {
+		t.Fatalf("Provisioner.Prepare() error = %v", err)
+	}
+
+	// The upload fails, the plan up to it is still written
+	if err := os.Remove(spec); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Provision(context.TODO(), packer.TestUi(t), &packer.MockCommunicator{}, map[string]interface{}{}); err == nil {
+		t.Fatal("Provisioner.Provision() error = nil, want the upload error")
+	}
+
+	b, err := os.ReadFile(planFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var plan dryRunPlan
+	if err := json.Unmarshal(b, &plan); err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Steps) != 4 {
+		t.Errorf("plan steps = %+v, want the 4 steps before the upload", plan.Steps)
+	}
+}
+
+func TestDryRunPlan_decodesPowerShell(t *testing.T) {
+	plan := (&Provisioner{}).newDryRunPlan(packer.TestUi(t))
+	cmd := &packer.RemoteCmd{Command: powerShellDialect{}.install("C:/goss.exe")}
+	if err := plan.Start(context.TODO(), cmd); err != nil {
+		t.Fatal(err)
+	}
+	if cmd.ExitStatus() != 0 {
+		t.Errorf("exit status = %d, want 0", cmd.ExitStatus())
+	}
+	if got, want := plan.Steps[0].Script, "& 'C:/goss.exe' --version; exit $LASTEXITCODE"; got != want {
+		t.Errorf("script = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,46 @@
+package goss
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/packer-plugin-sdk/guestexec"
+	"github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
+)
+
+// Communicator returns the communicator of the running build, see guestexec.ElevatedProvisioner
+func (p *Provisioner) Communicator() packer.Communicator {
+	return p.communicator
+}
+
+// ElevatedUser returns the user goss runs as on Windows, see guestexec.ElevatedProvisioner
+func (p *Provisioner) ElevatedUser() string {
+	return p.config.ElevatedUser
+}
+
+// ElevatedPassword returns the password of the elevated user, see guestexec.ElevatedProvisioner
+func (p *Provisioner) ElevatedPassword() string {
+	// Replace ElevatedPassword for winrm users who used this feature
+	p.config.ctx.Data = p.generatedData
+
+	elevatedPassword, _ := interpolate.Render(p.config.ElevatedPassword, &p.config.ctx)
+	return elevatedPassword
+}
+
+// elevated reports whether goss render and validate run in an elevated scheduled task
+func (p *Provisioner) elevated() bool {
+	return p.config.ElevatedUser != ""
+}
+
+// elevatedCmd uploads a scheduled task wrapper running command as the elevated user
+// and returns the command starting it. The wrapper streams the output of the task
+// and exits with its exit code.
+func (p *Provisioner) elevatedCmd(command string) (string, error) {
+	// Group the command so its own output redirection isn't replaced
+	// by the redirection to the log file of the task
+	cmd, err := guestexec.GenerateElevatedRunner(fmt.Sprintf("(%s)", command), p)
+	if err != nil {
+		return "", fmt.Errorf("Error generating elevated runner: %s", err)
+	}
+	return cmd, nil
+}
@@ -0,0 +1,38 @@
+package goss
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+func TestProvisioner_elevatedCmd(t *testing.T) {
+	comm := new(packer.MockCommunicator)
+	p := &Provisioner{
+		config: GossConfig{
+			TargetOs:         windows,
+			ElevatedUser:     "Administrator",
+			ElevatedPassword: "{{ .WinRMPassword }}",
+		},
+		communicator:  comm,
+		generatedData: map[string]interface{}{"WinRMPassword": "secret"},
+	}
+
+	cmd, err := p.elevatedCmd("cd /d C:/goss && goss.exe render > C:/goss-spec.yaml")
+	if err != nil {
+		t.Fatalf("Provisioner.elevatedCmd() error = %v", err)
+	}
+	if !strings.HasPrefix(cmd, "powershell -executionpolicy bypass -file") || !strings.Contains(cmd, comm.UploadPath) {
+		t.Errorf("Provisioner.elevatedCmd() = %v, want it to run the uploaded wrapper %s", cmd, comm.UploadPath)
+	}
+	for _, want := range []string{
+		"<UserId>Administrator</UserId>",
+		`$password = "secret"`,
+		"(cd /d C:/goss &amp;&amp; goss.exe render &gt; C:/goss-spec.yaml) &gt;",
+	} {
+		if !strings.Contains(comm.UploadData, want) {
+			t.Errorf("elevated wrapper does not contain %q", want)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package goss
+
+import (
+	"regexp"
+	"strings"
+)
+
+// failureKind tells why a goss command exited with a non-zero status
+type failureKind int
+
+const (
+	// testFailure means goss ran the tests and some of them failed
+	testFailure failureKind = iota
+	// executionError means goss couldn't run, e.g. the binary is missing
+	// or a spec or vars file doesn't parse
+	executionError
+)
+
+func (k failureKind) String() string {
+	if k == testFailure {
+		return "tests failed"
+	}
+	return "goss couldn't run"
+}
+
+// summaryPatterns match the results goss validate prints in each format once the tests ran
+var summaryPatterns = map[string]*regexp.Regexp{
+	"rspecish":       regexp.MustCompile(`Count: \d+, Failed: \d+`),
+	"documentation":  regexp.MustCompile(`Count: \d+, Failed: \d+`),
+	"json":           regexp.MustCompile(`"failed-count"`),
+	"json_oneline":   regexp.MustCompile(`"failed-count"`),
+	"junit":          regexp.MustCompile(`<testsuite`),
+	"tap":            regexp.MustCompile(`(?m)^1\.\.\d+`),
+	"nagios":         regexp.MustCompile(`GOSS CRITICAL`),
+	"nagios_verbose": regexp.MustCompile(`GOSS CRITICAL`),
+}
+
+// failedExitStatus returns the exit status of goss validate when tests failed
+func failedExitStatus(format string) int {
+	if strings.HasPrefix(format, "nagios") {
+		return 2
+	}
+	return 1
+}
+
+// outputFormat returns the format the validate results are printed in to the log
+func (p *Provisioner) outputFormat() string {
+	if len(p.config.Format) != 0 {
+		return p.config.Format[0]
+	}
+	return "rspecish"
+}
+
+// validateFormat returns the format goss validate runs with. When the provisioner
+// reads the JSON results they're printed in the output format by the provisioner.
+func (p *Provisioner) validateFormat() string {
+	if p.jsonResults() {
+		return "json"
+	}
+	return p.outputFormat()
+}
+
+// jsonResults reports whether the provisioner reads the JSON results of goss validate
+func (p *Provisioner) jsonResults() bool {
+	return len(p.config.SeverityPolicy) != 0 || p.config.ComplianceReport || p.config.MetricsFile != "" ||
+		p.config.Attestation || len(p.savedFormats()) != 0
+}
+
+// passed reports whether the goss run passed. Inspect mode and warn
+// severities let runs through that didn't.
+func (p *Provisioner) passed() bool {
+	if len(p.failedPhases) != 0 {
+		return false
+	}
+	return p.results == nil || p.results.Summary.FailedCount == 0
+}
+
+// failureKind classifies a non-zero exit of the goss phase message. Only validate
+// runs tests, it failed on tests when it exited with the status of failed tests
+// and printed its results. The silent format prints nothing, there any output
+// on stderr is taken as an execution error.
+func (p *Provisioner) failureKind(message string, exitStatus int, stdout, stderr string) failureKind {
+	if message != "validate" {
+		return executionError
+	}
+
+	format := p.validateFormat()
+	if exitStatus != failedExitStatus(format) {
+		return executionError
+	}
+	if pattern, ok := summaryPatterns[format]; ok {
+		if pattern.MatchString(stdout) {
+			return testFailure
+		}
+		return executionError
+	}
+	if strings.TrimSpace(stderr) == "" {
+		return testFailure
+	}
+	return executionError
+}
@@ -0,0 +1,188 @@
+package goss
+
+import (
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+func TestProvisioner_failureKind(t *testing.T) {
+	tests := []struct {
+		name       string
+		format     string
+		message    string
+		exitStatus int
+		stdout     string
+		stderr     string
+		want       failureKind
+	}{
+		{
+			name:       "failed tests",
+			message:    "validate",
+			exitStatus: 1,
+			stdout:     "Failures/Skipped:\n\nCount: 12, Failed: 2, Skipped: 0\n",
+			want:       testFailure,
+		},
+		{
+			name:       "parse error",
+			message:    "validate",
+			exitStatus: 1,
+			stderr:     "Error: could not read json data in goss.yaml",
+			want:       executionError,
+		},
+		{
+			name:       "missing binary",
+			message:    "validate",
+			exitStatus: 127,
+			stderr:     "sh: /tmp/goss-0.4.2-linux-amd64: not found",
+			want:       executionError,
+		},
+		{
+			name:       "render",
+			message:    "render",
+			exitStatus: 1,
+			stdout:     "Count: 12, Failed: 2, Skipped: 0",
+			want:       executionError,
+		},
+		{
+			name:       "json",
+			format:     "json",
+			message:    "validate",
+			exitStatus: 1,
+			stdout:     `{"summary":{"failed-count":1}}`,
+			want:       testFailure,
+		},
+		{
+			name:       "nagios",
+			format:     "nagios",
+			message:    "validate",
+			exitStatus: 2,
+			stdout:     "GOSS CRITICAL - Count: 12, Failed: 2",
+			want:       testFailure,
+		},
+		{
+			name:       "nagios unexpected status",
+			format:     "nagios",
+			message:    "validate",
+			exitStatus: 1,
+			stdout:     "GOSS CRITICAL - Count: 12, Failed: 2",
+			want:       executionError,
+		},
+		{
+			name:       "silent",
+			format:     "silent",
+			message:    "validate",
+			exitStatus: 1,
+			want:       testFailure,
+		},
+		{
+			name:       "silent with error",
+			format:     "silent",
+			message:    "validate",
+			exitStatus: 1,
+			stderr:     "Error: open vars.yaml: no such file or directory",
+			want:       executionError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &Provisioner{}
+			if tt.format != "" {
+				p.config.Format = []string{tt.format}
+			}
+			if got := p.failureKind(tt.message, tt.exitStatus, tt.stdout, tt.stderr); got != tt.want {
+				t.Errorf("Provisioner.failureKind() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProvisioner_runGossCmdInspect(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  GossConfig
+		comm    *packer.MockCommunicator
+		wantErr bool
+	}{
+		{
+			name:   "failed tests",
+			config: GossConfig{Inspect: true},
+			comm: &packer.MockCommunicator{
+				StartStdout:     "Count: 12, Failed: 2, Skipped: 0\n",
+				StartExitStatus: 1,
+			},
+			wantErr: false,
+		},
+		{
+			name:   "execution error",
+			config: GossConfig{Inspect: true},
+			comm: &packer.MockCommunicator{
+				StartStderr:     "sh: goss: not found\n",
+				StartExitStatus: 127,
+			},
+			wantErr: true,
+		},
+		{
+			name:   "allowed execution error",
+			config: GossConfig{Inspect: true, AllowExecutionErrors: true},
+			comm: &packer.MockCommunicator{
+				StartStderr:     "sh: goss: not found\n",
+				StartExitStatus: 127,
+			},
+			wantErr: false,
+		},
+		{
+			name:   "failed tests without inspect",
+			config: GossConfig{},
+			comm: &packer.MockCommunicator{
+				StartStdout:     "Count: 12, Failed: 2, Skipped: 0\n",
+				StartExitStatus: 1,
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &Provisioner{
+				config: tt.config,
+			}
+			err := p.runGossCmd(packer.TestUi(t), tt.comm, &packer.RemoteCmd{Command: "goss validate"}, "validate")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Provisioner.runGossCmd() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestProvisioner_passed(t *testing.T) {
+	tests := []struct {
+		name         string
+		failedPhases map[string]bool
+		failed       int
+		want         bool
+	}{
+		{
+			name: "passed",
+			want: true,
+		},
+		{
+			name:         "inspect",
+			failedPhases: map[string]bool{"validate": true},
+			want:         false,
+		},
+		{
+			name:   "warn severity",
+			failed: 2,
+			want:   false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &Provisioner{failedPhases: tt.failedPhases, results: &gossResults{}}
+			p.results.Summary.FailedCount = tt.failed
+			if got := p.passed(); got != tt.want {
+				t.Errorf("Provisioner.passed() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
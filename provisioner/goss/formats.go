@@ -0,0 +1,86 @@
+package goss
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// formatExtensions are the file extensions of the results saved for each format
+var formatExtensions = map[string]string{
+	"documentation":  "txt",
+	"json":           "json",
+	"json_oneline":   "json",
+	"junit":          "xml",
+	"nagios":         "txt",
+	"nagios_verbose": "txt",
+	"rspecish":       "txt",
+	"tap":            "tap",
+}
+
+// resultsFile returns the file name the validate results in format are saved as
+func resultsFile(format string) string {
+	return fmt.Sprintf("goss-results-%s.%s", format, formatExtensions[format])
+}
+
+// formats returns the configured formats, the first one is printed to the log
+func (p *Provisioner) formats() []string {
+	return append([]string{p.outputFormat()}, p.savedFormats()...)
+}
+
+// savedFormats returns the formats after the first one, their results are saved to local files
+func (p *Provisioner) savedFormats() []string {
+	if len(p.config.Format) < 2 {
+		return nil
+	}
+	return p.config.Format[1:]
+}
+
+// saveFormats writes the validate results in each of the saved formats to the current
+// directory. They're rendered from the JSON results of the run that decided the build,
+// failed tests are part of the results. Errors are reported but don't fail the build.
+func (p *Provisioner) saveFormats(ui packer.Ui) {
+	if len(p.savedFormats()) == 0 {
+		return
+	}
+	if p.results == nil {
+		ui.Message("No goss results to save")
+		return
+	}
+
+	for _, format := range p.savedFormats() {
+		if err := p.saveFormat(ui, format); err != nil {
+			ui.Error(fmt.Sprintf("Error saving %s results: %s", format, err))
+		}
+	}
+}
+
+func (p *Provisioner) saveFormat(ui packer.Ui, format string) error {
+	out, err := p.renderResults(p.results, format)
+	if err != nil {
+		return err
+	}
+	local := resultsFile(format)
+	ui.Message(fmt.Sprintf("Saving %s results to %s", format, local))
+	return os.WriteFile(local, out, 0644)
+}
+
+// downloadFile downloads the remote file to local. In a dry run only the download is recorded.
+func (p *Provisioner) downloadFile(comm packer.Communicator, remote, local string) error {
+	if plan, ok := comm.(*dryRunPlan); ok {
+		// Don't truncate local files of an earlier run
+		plan.record(planStep{Action: "download", Source: remote, Destination: local})
+		return nil
+	}
+
+	f, err := os.Create(local)
+	if err != nil {
+		return fmt.Errorf("Error opening: %s", err)
+	}
+	if err = comm.Download(remote, f); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("Error downloading %s: %s", remote, err)
+	}
+	return f.Close()
+}
@@ -0,0 +1,154 @@
+package goss
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+func Test_resultsFile(t *testing.T) {
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{format: "junit", want: "goss-results-junit.xml"},
+		{format: "json", want: "goss-results-json.json"},
+		{format: "json_oneline", want: "goss-results-json_oneline.json"},
+		{format: "tap", want: "goss-results-tap.tap"},
+		{format: "documentation", want: "goss-results-documentation.txt"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			if got := resultsFile(tt.format); got != tt.want {
+				t.Errorf("resultsFile() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProvisioner_saveFormats(t *testing.T) {
+	tests := []struct {
+		name      string
+		format    []string
+		results   string
+		wantFiles []string
+	}{
+		{
+			name:    "single format",
+			format:  []string{"rspecish"},
+			results: renderResults,
+		},
+		{
+			name:      "failed tests",
+			format:    []string{"rspecish", "junit", "json"},
+			results:   renderResults,
+			wantFiles: []string{"goss-results-junit.xml", "goss-results-json.json"},
+		},
+		{
+			name:   "execution error",
+			format: []string{"rspecish", "junit"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chdir(t, t.TempDir())
+			p := &Provisioner{
+				config: GossConfig{TargetOs: linux, Format: tt.format},
+			}
+			if tt.results != "" {
+				results, err := parseResults(tt.results)
+				if err != nil {
+					t.Fatal(err)
+				}
+				p.results = results
+			}
+			p.saveFormats(packer.TestUi(t))
+
+			entries, err := os.ReadDir(".")
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, entry := range entries {
+				got = append(got, entry.Name())
+			}
+			for _, want := range tt.wantFiles {
+				if !strings.Contains(strings.Join(got, ","), want) {
+					t.Errorf("saved files %v, want %s", got, want)
+				}
+			}
+			if len(got) != len(tt.wantFiles) {
+				t.Errorf("saved files %v, want %v", got, tt.wantFiles)
+			}
+		})
+	}
+}
+
+func TestProvisioner_runGossSavedFormats(t *testing.T) {
+	chdir(t, t.TempDir())
+	p := &Provisioner{
+		config: GossConfig{
+			TargetOs:             linux,
+			Format:               []string{"rspecish", "junit"},
+			Inspect:              true,
+			AllowExecutionErrors: true,
+		},
+	}
+	comm := &packer.MockCommunicator{
+		StartStdout:     renderResults,
+		StartExitStatus: 1,
+	}
+	if err := p.runGoss(packer.TestUi(t), comm); err != nil {
+		t.Fatalf("Provisioner.runGoss() error = %v", err)
+	}
+	if !strings.Contains(comm.StartCmd.Command, "validate") || !strings.Contains(comm.StartCmd.Command, "-f json") {
+		t.Errorf("last command = %q, want validate with -f json", comm.StartCmd.Command)
+	}
+	saved, err := os.ReadFile("goss-results-junit.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(saved), `failures="1"`) {
+		t.Errorf("junit results = %q, want the failed test", saved)
+	}
+}
+
+func TestProvisioner_formatOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		options []string
+		format  string
+		want    string
+	}{
+		{
+			name:    "none",
+			options: nil,
+			format:  "nagios",
+			want:    "",
+		},
+		{
+			name:    "several",
+			options: []string{"perfdata", "verbose"},
+			format:  "nagios",
+			want:    "-o perfdata -o verbose",
+		},
+		{
+			name:    "only supported",
+			options: []string{"perfdata", "pretty"},
+			format:  "json",
+			want:    "-o pretty",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &Provisioner{
+				config: GossConfig{FormatOptions: tt.options},
+			}
+			if got := p.formatOptions(tt.format); got != tt.want {
+				t.Errorf("Provisioner.formatOptions() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
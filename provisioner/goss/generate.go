@@ -0,0 +1,141 @@
+package goss
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+
+	"github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+const (
+	modeValidate = "validate"
+	modeGenerate = "generate"
+
+	// gossAutoadd is the generate key of the names passed to goss autoadd
+	gossAutoadd = "autoadd"
+	// gossGeneratedFile is the spec goss add writes to, locally and on the remote host
+	gossGeneratedFile  = "goss.yaml"
+	defaultGenerateDir = "goss-generated"
+)
+
+var validModes = []string{modeValidate, modeGenerate}
+
+// gossAddTypes are the resource types goss add supports
+var gossAddTypes = []string{
+	"addr", "command", "dns", "file", "group", "http", "interface",
+	"kernel-param", "mount", "package", "port", "process", "service", "user",
+}
+
+// generating reports whether specs are generated from the remote host instead of validated
+func (p *Provisioner) generating() bool {
+	return p.config.Mode == modeGenerate
+}
+
+// generateDir returns the local directory the generated spec is downloaded to
+func (p *Provisioner) generateDir() string {
+	if p.config.GenerateDir == "" {
+		return defaultGenerateDir
+	}
+	return p.config.GenerateDir
+}
+
+// checkGenerate validates the generate settings
+func (p *Provisioner) checkGenerate() []error {
+	var errs []error
+	// Generating ends after goss add, these settings of validate runs would be ignored
+	for _, setting := range []struct {
+		name string
+		set  bool
+	}{
+		{"tests", len(p.config.Tests) != 0},
+		{"persist", p.config.Persist != nil},
+		{"baseline_spec", p.config.BaselineSpec != ""},
+		{"attestation", p.config.Attestation},
+	} {
+		if setting.set {
+			errs = append(errs, fmt.Errorf("%s can't be used in generate mode", setting.name))
+		}
+	}
+
+	if len(p.config.Generate) == 0 {
+		return append(errs, fmt.Errorf("generate must list the resources to add in generate mode"))
+	}
+
+	for _, kind := range sortedGenerateKeys(p.config.Generate) {
+		if kind == gossAutoadd {
+			continue
+		}
+		if !oneOf(kind, gossAddTypes) {
+			errs = append(errs, fmt.Errorf("Invalid generate resource type %s. Valid options: %v or %s",
+				kind, gossAddTypes, gossAutoadd))
+		}
+	}
+	return errs
+}
+
+// sortedGenerateKeys returns the resource types of generate in a stable order
+func sortedGenerateKeys(generate map[string][]string) []string {
+	keys := make([]string, 0, len(generate))
+	for key := range generate {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// generateCmds returns the goss add and autoadd commands building the spec on the remote host.
+// goss add appends to an existing spec, the spec of an earlier run is removed first.
+func (p *Provisioner) generateCmds() []string {
+	d := p.dialect()
+	gossfile := path.Join(filepath.ToSlash(p.config.RemotePath), gossGeneratedFile)
+
+	cmds := []string{d.remove(gossfile)}
+	for _, kind := range sortedGenerateKeys(p.config.Generate) {
+		for _, name := range p.config.Generate[kind] {
+			args := fmt.Sprintf("--gossfile %s add %s %s", d.quote(gossfile), kind, d.quote(name))
+			if kind == gossAutoadd {
+				args = fmt.Sprintf("--gossfile %s autoadd %s", d.quote(gossfile), d.quote(name))
+			}
+			cmds = append(cmds, d.exec(p.config.RemotePath, p.envVars(), p.config.UseSudo,
+				p.config.DownloadPath, args, ""))
+		}
+	}
+	return cmds
+}
+
+// generateSpec adds the configured resources of the remote host to a goss spec
+// and downloads it to generate_dir
+func (p *Provisioner) generateSpec(ui packer.Ui, comm packer.Communicator) error {
+	for _, cmd := range p.generateCmds() {
+		ui.Say(fmt.Sprintf("Running GOSS generate command: %s", cmd))
+		command := cmd
+		if p.elevated() {
+			var err error
+			if command, err = p.elevatedCmd(cmd); err != nil {
+				return err
+			}
+		}
+
+		rc := &packer.RemoteCmd{Command: command}
+		if err := rc.RunWithUi(context.TODO(), comm, ui); err != nil {
+			return err
+		}
+		if rc.ExitStatus() != 0 {
+			return fmt.Errorf("goss non-zero exit status")
+		}
+	}
+
+	if _, dryRun := comm.(*dryRunPlan); !dryRun {
+		if err := os.MkdirAll(p.generateDir(), 0755); err != nil {
+			return err
+		}
+	}
+	remote := path.Join(filepath.ToSlash(p.config.RemotePath), gossGeneratedFile)
+	local := filepath.Join(p.generateDir(), gossGeneratedFile)
+	ui.Message(fmt.Sprintf("Downloading generated spec %s to %s", remote, local))
+	return p.downloadFile(comm, remote, local)
+}
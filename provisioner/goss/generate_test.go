@@ -0,0 +1,98 @@
+package goss
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+func TestProvisioner_generateCmds(t *testing.T) {
+	p := &Provisioner{
+		config: GossConfig{
+			TargetOs:     linux,
+			RemotePath:   "/tmp/goss",
+			DownloadPath: "/tmp/goss-bin",
+			UseSudo:      true,
+			Generate: map[string][]string{
+				"service": {"nginx"},
+				"autoadd": {"sshd"},
+				"port":    {"tcp:80", "tcp:443"},
+			},
+		},
+	}
+	want := []string{
+		"rm -f '/tmp/goss/goss.yaml'",
+		"cd /tmp/goss && sudo /tmp/goss-bin --gossfile '/tmp/goss/goss.yaml' autoadd 'sshd'",
+		"cd /tmp/goss && sudo /tmp/goss-bin --gossfile '/tmp/goss/goss.yaml' add port 'tcp:80'",
+		"cd /tmp/goss && sudo /tmp/goss-bin --gossfile '/tmp/goss/goss.yaml' add port 'tcp:443'",
+		"cd /tmp/goss && sudo /tmp/goss-bin --gossfile '/tmp/goss/goss.yaml' add service 'nginx'",
+	}
+	if got := p.generateCmds(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Provisioner.generateCmds() = %v, want %v", got, want)
+	}
+}
+
+func TestProvisioner_ProvisionGenerate(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "specs")
+	p := &Provisioner{}
+	err := p.Prepare(map[string]interface{}{
+		"mode":         "generate",
+		"generate":     map[string][]string{"service": {"nginx"}},
+		"generate_dir": dir,
+	})
+	if err != nil {
+		t.Fatalf("Provisioner.Prepare() error = %v", err)
+	}
+
+	comm := &packer.MockCommunicator{
+		DownloadData: "service:\n  nginx:\n    enabled: true\n    running: true\n",
+	}
+	if err := p.Provision(context.TODO(), packer.TestUi(t), comm, map[string]interface{}{}); err != nil {
+		t.Fatalf("Provisioner.Provision() error = %v", err)
+	}
+	if comm.UploadCalled {
+		t.Errorf("tests uploaded in generate mode")
+	}
+	if comm.DownloadPath != "/tmp/goss/goss.yaml" {
+		t.Errorf("downloaded %s, want /tmp/goss/goss.yaml", comm.DownloadPath)
+	}
+
+	b, err := os.ReadFile(filepath.Join(dir, "goss.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != comm.DownloadData {
+		t.Errorf("generated spec = %q, want %q", b, comm.DownloadData)
+	}
+}
+
+func TestProvisioner_ProvisionGenerateDryRun(t *testing.T) {
+	planFile := filepath.Join(t.TempDir(), "plan.json")
+	p := &Provisioner{}
+	err := p.Prepare(map[string]interface{}{
+		"mode":         "generate",
+		"generate":     map[string][]string{"service": {"nginx"}},
+		"dry_run":      true,
+		"dry_run_file": planFile,
+	})
+	if err != nil {
+		t.Fatalf("Provisioner.Prepare() error = %v", err)
+	}
+
+	comm := &packer.MockCommunicator{}
+	if err := p.Provision(context.TODO(), packer.TestUi(t), comm, map[string]interface{}{}); err != nil {
+		t.Fatalf("Provisioner.Provision() error = %v", err)
+	}
+	b, err := os.ReadFile(planFile)
+	if err != nil {
+		t.Fatalf("plan not written: %s", err)
+	}
+	if !strings.Contains(string(b), "add service") {
+		t.Errorf("plan = %s, want the goss add command", b)
+	}
+}
@@ -0,0 +1,144 @@
+package goss
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/packer-plugin-sdk/packer"
+	"gopkg.in/yaml.v3"
+)
+
+// gossResourceTypes are the top level keys allowed in a goss spec file
+var gossResourceTypes = []string{
+	"addr", "command", "dns", "file", "gossfile", "group", "http", "interface",
+	"kernel-param", "matching", "mount", "package", "port", "process", "service", "user",
+}
+
+// isTemplated reports whether s contains goss (Go) template actions
+func isTemplated(s string) bool {
+	return strings.Contains(s, "{{")
+}
+
+// isSpecFile reports whether a file is linted based on its extension
+func isSpecFile(file string) bool {
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".yaml", ".yml", ".json":
+		return true
+	default:
+		return false
+	}
+}
+
+// lintSpecs checks the uploaded goss spec files for syntax errors and unknown resource types
+func (p *Provisioner) lintSpecs() error {
+	plan, err := p.uploadPlan()
+	if err != nil {
+		return err
+	}
+
+	varsFile := ""
+	if p.config.VarsFile != "" {
+		varsFile, _ = filepath.Abs(p.config.VarsFile)
+	}
+
+	var errs *packer.MultiError
+	for _, u := range plan {
+		if !isSpecFile(u.src) {
+			continue
+		}
+		if abs, _ := filepath.Abs(u.src); abs == varsFile {
+			continue
+		}
+		for _, err := range lintSpec(u.src) {
+			errs = packer.MultiErrorAppend(errs, err)
+		}
+	}
+
+	if errs != nil && len(errs.Errors) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// lintSpec parses a single goss spec file and returns the problems found in it
+func lintSpec(file string) []error {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return []error{err}
+	}
+	templated := isTemplated(string(content))
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		if templated {
+			// Templates only have to be valid YAML once goss rendered them
+			log.Printf("Skipping lint of templated goss spec %s: %s", file, err)
+			return nil
+		}
+		return []error{fmt.Errorf("%s: %s", file, err)}
+	}
+	if len(doc.Content) == 0 {
+		return nil
+	}
+
+	root := doc.Content[0]
+	if !isMappingOfMappings(root) {
+		// Other data uploaded with the tests, e.g. additional vars files
+		log.Printf("Skipping lint of %s, it isn't a mapping of resource types", file)
+		return nil
+	}
+
+	var errs []error
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		key, value := root.Content[i], root.Content[i+1]
+		if isTemplated(key.Value) {
+			continue
+		}
+		if !isResourceType(key.Value) {
+			errs = append(errs, fmt.Errorf("%s:%d: unknown resource type '%s'", file, key.Line, key.Value))
+			continue
+		}
+		if value.Kind == yaml.ScalarNode && (value.Tag == "!!null" || isTemplated(value.Value)) {
+			continue
+		}
+		if value.Kind != yaml.MappingNode {
+			errs = append(errs, fmt.Errorf("%s:%d: resource type '%s' must be a mapping of resources", file, value.Line, key.Value))
+			continue
+		}
+		for j := 0; j+1 < len(value.Content); j += 2 {
+			name, attrs := value.Content[j], value.Content[j+1]
+			if attrs.Kind == yaml.MappingNode || attrs.Tag == "!!null" || isTemplated(name.Value) || isTemplated(attrs.Value) {
+				continue
+			}
+			errs = append(errs, fmt.Errorf("%s:%d: %s '%s' must be a mapping of attributes", file, attrs.Line, key.Value, name.Value))
+		}
+	}
+	return errs
+}
+
+// isMappingOfMappings reports whether a document is shaped like a goss spec,
+// a mapping whose values are mappings, empty or templated. Unknown keys of such
+// a document are typos of resource types, e.g. "servce", even when it has no known one.
+func isMappingOfMappings(root *yaml.Node) bool {
+	if root.Kind != yaml.MappingNode {
+		return false
+	}
+	for i := 1; i < len(root.Content); i += 2 {
+		value := root.Content[i]
+		if value.Kind == yaml.MappingNode {
+			continue
+		}
+		if value.Kind == yaml.ScalarNode && (value.Tag == "!!null" || isTemplated(value.Value)) {
+			continue
+		}
+		return false
+	}
+	return true
+}
+
+func isResourceType(key string) bool {
+	return oneOf(key, gossResourceTypes)
+}
@@ -0,0 +1,129 @@
+package goss
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLintSpec(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+	}{
+		{
+			name: "valid",
+			content: `package:
+  nginx:
+    installed: true
+service:
+  nginx:
+    running: true
+`,
+		},
+		{
+			name: "unknown resource type",
+			content: `package:
+  nginx:
+    installed: true
+pakage:
+  curl:
+    installed: true
+`,
+			want: []string{":4: unknown resource type 'pakage'"},
+		},
+		{
+			name: "resource without attributes",
+			content: `service:
+  nginx: running
+`,
+			want: []string{":2: service 'nginx' must be a mapping of attributes"},
+		},
+		{
+			name:    "syntax error",
+			content: "package:\n  nginx:\n    installed: true\n   version: [1\n",
+			want:    []string{"yaml: line"},
+		},
+		{
+			name:    "json",
+			content: `{"file": {"/etc/passwd": {"exists": true}}, "files": {}}`,
+			want:    []string{":1: unknown resource type 'files'"},
+		},
+		{
+			name: "only a typo",
+			content: `servce:
+  nginx:
+    running: true
+`,
+			want: []string{":1: unknown resource type 'servce'"},
+		},
+		{
+			name:    "list",
+			content: `["nginx", "curl"]`,
+		},
+		{
+			name:    "vars",
+			content: "packages:\n  - nginx\nusers: {www-data: {}}\n",
+		},
+		{
+			name: "template that is not valid yaml",
+			content: `package:
+{{- range .Vars.packages }}
+  {{ . }}:
+    installed: true
+{{- end }}
+`,
+		},
+		{
+			name: "templated value",
+			content: `package: {{ .Vars.packages | toJson }}
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "goss.yaml")
+			if err := os.WriteFile(file, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			errs := lintSpec(file)
+			if len(errs) != len(tt.want) {
+				t.Fatalf("lintSpec() = %v, want %d errors", errs, len(tt.want))
+			}
+			for i, err := range errs {
+				if !strings.Contains(err.Error(), tt.want[i]) {
+					t.Errorf("lintSpec() error = %v, want it to contain %q", err, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestProvisioner_PrepareLint(t *testing.T) {
+	root := writeTree(t, "vars.yaml")
+	spec := filepath.Join(root, "goss.yaml")
+	if err := os.WriteFile(spec, []byte("package:\n  curl: {}\npakage:\n  nginx: {}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	p := &Provisioner{}
+	if err := p.Prepare(map[string]interface{}{"tests": []string{spec}}); err == nil {
+		t.Error("Provisioner.Prepare() expected a lint error")
+	}
+
+	p = &Provisioner{}
+	if err := p.Prepare(map[string]interface{}{"tests": []string{spec}, "skip_lint": true}); err != nil {
+		t.Errorf("Provisioner.Prepare() error = %v with skip_lint", err)
+	}
+
+	p = &Provisioner{}
+	vars := filepath.Join(root, "vars.yaml")
+	if err := os.WriteFile(vars, []byte("packages: [nginx]\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Prepare(map[string]interface{}{"tests": []string{root}, "vars_file": vars, "skip_lint": false, "exclude": []string{"goss.yaml"}}); err != nil {
+		t.Errorf("Provisioner.Prepare() error = %v, vars file should not be linted", err)
+	}
+}
@@ -0,0 +1,87 @@
+package goss
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// metricPhases are the phases of Provision whose duration is exported
+var metricPhases = []string{"install", "upload", "render", "validate"}
+
+// timed runs fn and adds its duration to phase
+func (p *Provisioner) timed(phase string, fn func() error) error {
+	start := time.Now()
+	err := fn()
+	if p.durations == nil {
+		p.durations = make(map[string]time.Duration)
+	}
+	p.durations[phase] += time.Since(start)
+	return err
+}
+
+// labelEscape escapes a label value of the OpenMetrics text format
+func labelEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
+
+// metricLabels returns the labels every metric carries
+func (p *Provisioner) metricLabels() string {
+	return fmt.Sprintf(`build_name="%s",target_os="%s",goss_version="%s"`,
+		labelEscape(p.config.PackerBuildName), labelEscape(strings.ToLower(p.config.TargetOs)),
+		labelEscape(p.config.Version))
+}
+
+// metrics returns the OpenMetrics text of the run
+func (p *Provisioner) metrics(success bool) []byte {
+	var b bytes.Buffer
+	labels := p.metricLabels()
+	gauge := func(name, help string) {
+		fmt.Fprintf(&b, "# TYPE %s gauge\n# HELP %s %s\n", name, name, help)
+	}
+
+	gauge("goss_success", "Whether the goss provisioner succeeded.")
+	value := 0
+	if success {
+		value = 1
+	}
+	fmt.Fprintf(&b, "goss_success{%s} %d\n", labels, value)
+
+	if p.results != nil {
+		gauge("goss_tests", "Number of goss tests run by validate.")
+		fmt.Fprintf(&b, "goss_tests{%s} %d\n", labels, p.results.Summary.TestCount)
+		gauge("goss_tests_failed", "Number of failed goss tests.")
+		fmt.Fprintf(&b, "goss_tests_failed{%s} %d\n", labels, p.results.Summary.FailedCount)
+		gauge("goss_tests_skipped", "Number of skipped goss tests.")
+		fmt.Fprintf(&b, "goss_tests_skipped{%s} %d\n", labels, p.results.Summary.SkippedCount)
+	}
+
+	gauge("goss_phase_duration_seconds", "Duration of each phase of the goss provisioner.")
+	for _, phase := range metricPhases {
+		if d, ok := p.durations[phase]; ok {
+			fmt.Fprintf(&b, "goss_phase_duration_seconds{%s,phase=\"%s\"} %g\n", labels, phase, d.Seconds())
+		}
+	}
+
+	b.WriteString("# EOF\n")
+	return b.Bytes()
+}
+
+// writeMetrics writes the metrics of the run to metrics_file
+func (p *Provisioner) writeMetrics(ui packer.Ui, success bool) {
+	// Write to a temporary file first so collectors never read a partial file
+	tmp := p.config.MetricsFile + ".tmp"
+	err := os.WriteFile(tmp, p.metrics(success), 0644)
+	if err == nil {
+		err = os.Rename(tmp, p.config.MetricsFile)
+	}
+	if err != nil {
+		ui.Error(fmt.Sprintf("Error writing metrics to %s: %s", p.config.MetricsFile, err))
+		return
+	}
+	ui.Message(fmt.Sprintf("Wrote metrics to %s", p.config.MetricsFile))
+}
@@ -0,0 +1,100 @@
+package goss
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/common"
+	"github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+func TestProvisioner_metrics(t *testing.T) {
+	p := &Provisioner{
+		config: GossConfig{
+			PackerConfig: common.PackerConfig{PackerBuildName: "amazon-ebs.web"},
+			TargetOs:     linux,
+			Version:      "0.4.2",
+		},
+		durations: map[string]time.Duration{
+			"install":  1500 * time.Millisecond,
+			"validate": 2 * time.Second,
+		},
+		results: &gossResults{},
+	}
+	p.results.Summary.TestCount = 12
+	p.results.Summary.FailedCount = 2
+	p.results.Summary.SkippedCount = 1
+
+	got := string(p.metrics(false))
+	labels := `build_name="amazon-ebs.web",target_os="linux",goss_version="0.4.2"`
+	for _, want := range []string{
+		"# TYPE goss_success gauge\n",
+		"goss_success{" + labels + "} 0\n",
+		"goss_tests{" + labels + "} 12\n",
+		"goss_tests_failed{" + labels + "} 2\n",
+		"goss_tests_skipped{" + labels + "} 1\n",
+		"goss_phase_duration_seconds{" + labels + `,phase="install"} 1.5` + "\n",
+		"goss_phase_duration_seconds{" + labels + `,phase="validate"} 2` + "\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Provisioner.metrics() doesn't contain %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, `phase="upload"`) {
+		t.Errorf("Provisioner.metrics() contains a phase that didn't run:\n%s", got)
+	}
+	if !strings.HasSuffix(got, "# EOF\n") {
+		t.Errorf("Provisioner.metrics() doesn't end with # EOF:\n%s", got)
+	}
+}
+
+func TestProvisioner_timed(t *testing.T) {
+	p := &Provisioner{}
+	_ = p.timed("render", func() error { return nil })
+	_ = p.timed("render", func() error { return nil })
+	if len(p.durations) != 1 {
+		t.Errorf("durations = %v, want a single render phase", p.durations)
+	}
+}
+
+func TestProvisioner_ProvisionMetrics(t *testing.T) {
+	metricsFile := filepath.Join(t.TempDir(), "goss.prom")
+	p := &Provisioner{}
+	err := p.Prepare(map[string]interface{}{
+		"tests":             []string{"../../example/goss/goss.yaml"},
+		"packer_build_name": "docker.test",
+		"metrics_file":      metricsFile,
+		"skip_download":     true,
+	})
+	if err != nil {
+		t.Fatalf("Provisioner.Prepare() error = %v", err)
+	}
+
+	comm := &packer.MockCommunicator{
+		StartStdout: `{"results": [], "summary": {"test-count": 0, "failed-count": 0}}`,
+	}
+	if err := p.Provision(context.TODO(), packer.TestUi(t), comm, map[string]interface{}{}); err != nil {
+		t.Fatalf("Provisioner.Provision() error = %v", err)
+	}
+
+	b, err := os.ReadFile(metricsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`goss_success{build_name="docker.test",target_os="linux",goss_version="0.4.2"} 1`,
+		`goss_tests{build_name="docker.test",target_os="linux",goss_version="0.4.2"} 0`,
+		`phase="install"`,
+		`phase="upload"`,
+		`phase="render"`,
+		`phase="validate"`,
+	} {
+		if !strings.Contains(string(b), want) {
+			t.Errorf("metrics file doesn't contain %q:\n%s", want, b)
+		}
+	}
+}
@@ -1,36 +1,48 @@
-//go:generate packer-sdc mapstructure-to-hcl2 -type GossConfig
+//go:generate packer-sdc mapstructure-to-hcl2 -type GossConfig,PersistConfig
 
 package goss
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"log"
+	"net/url"
 	"os"
+	"path"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/hashicorp/hcl/v2/hcldec"
 
+	"github.com/hashicorp/packer-plugin-sdk/common"
 	"github.com/hashicorp/packer-plugin-sdk/packer"
 	"github.com/hashicorp/packer-plugin-sdk/template/config"
 	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
 )
 
 const (
-	gossSpecFile      = "/tmp/goss-spec.yaml"
-	gossDebugSpecFile = "/tmp/debug-goss-spec.yaml"
+	gossSpecFile      = "goss-spec.yaml"
+	gossDebugSpecFile = "debug-goss-spec.yaml"
+	caBundleFile      = "goss-ca-bundle.pem"
 	linux             = "Linux"
 	windows           = "Windows"
+	darwin            = "Darwin"
 )
 
 // GossConfig holds the config data coming in from the packer template
 type GossConfig struct {
+	common.PackerConfig `mapstructure:",squash"`
+
 	// Goss installation
-	Version      string
-	Arch         string
+	Version string
+	Arch    string
+	// The URL goss is downloaded from. It's a template with the fields
+	// {{.Version}}, {{.OS}}, {{.Arch}} and {{.Ext}} for internal mirrors.
 	URL          string
 	DownloadPath string `mapstructure:"download_path"`
 	Username     string
@@ -39,19 +51,97 @@ type GossConfig struct {
 	Inspect      bool
 	TargetOs     string `mapstructure:"target_os"`
 
+	// validate runs the tests, generate builds a spec from the remote host
+	// with goss add and autoadd instead. Defaults to validate
+	Mode string `mapstructure:"mode"`
+
+	// The resources goss add adds to the generated spec by resource type,
+	// e.g. package or service, and the names passed to goss autoadd under autoadd
+	Generate map[string][]string `mapstructure:"generate"`
+
+	// The local directory the generated goss.yaml is downloaded to.
+	// This defaults to goss-generated
+	GenerateDir string `mapstructure:"generate_dir"`
+
 	// An array of tests to run.
+	// Entries can be glob patterns, "**" matches any number of directories.
 	Tests []string
 
+	// Glob patterns of files and directories that are not uploaded.
+	// Patterns without a slash match a file or directory name at any depth,
+	// patterns with a slash match the path relative to the tests entry.
+	Exclude []string `mapstructure:"exclude"`
+
 	// Goss options for retry and timeouts
 	RetryTimeout string `mapstructure:"retry_timeout"`
 	Sleep        string `mapstructure:"sleep"`
 
+	// How many times the whole goss validate command is run before failing.
+	// Every attempt retries for up to retry_timeout. This defaults to 1
+	MaxRetryAttempts int `mapstructure:"max_retry_attempts"`
+
+	// Proceed in inspect mode even when goss couldn't run, e.g. because
+	// the binary is missing or a spec doesn't parse, rather than only
+	// when tests failed
+	AllowExecutionErrors bool `mapstructure:"allow_execution_errors"`
+
+	// The goss meta key failed tests are grouped by, it defaults to severity
+	SeverityKey string `mapstructure:"severity_key"`
+
+	// What failed tests of each severity do to the build: fail, warn or ignore.
+	// The default entry applies to failed tests without a listed severity.
+	// When set, validate reports its results as JSON and inspect is ignored for failed tests
+	SeverityPolicy map[string]string `mapstructure:"severity_policy"`
+
+	// Write a compliance matrix of the controls in the meta.control field of
+	// the tests as Markdown, HTML and CSV next to the downloaded spec files
+	ComplianceReport bool `mapstructure:"compliance_report"`
+
+	// Local file the test counts and phase durations are written to in the
+	// OpenMetrics text format, e.g. for the node exporter textfile collector
+	MetricsFile string `mapstructure:"metrics_file"`
+
+	// Write an in-toto statement about the rendered spec and the validate results,
+	// signed with attestation_key, to goss-attestation.intoto.jsonl
+	Attestation bool `mapstructure:"attestation"`
+
+	// Local PKCS #8 PEM file with the ed25519 private key signing the attestation
+	AttestationKey string `mapstructure:"attestation_key"`
+
 	// Use Sudo
 	UseSudo bool `mapstructure:"use_sudo"`
 
+	// Run goss render and validate on Windows as this user in an elevated
+	// scheduled task, like the powershell provisioner does
+	ElevatedUser     string `mapstructure:"elevated_user"`
+	ElevatedPassword string `mapstructure:"elevated_password"`
+
+	// The shell remote commands are written for: sh, cmd or powershell.
+	// Defaults to sh, or on Windows to powershell over WinRM and cmd otherwise
+	Shell string `mapstructure:"shell"`
+
 	// skip ssl check flag
+	// Deprecated: trust the certificate of the download server with ca_bundle instead
 	SkipSSLChk bool `mapstructure:"skip_ssl"`
 
+	// Proxies used to download goss
+	HTTPProxy  string `mapstructure:"http_proxy"`
+	HTTPSProxy string `mapstructure:"https_proxy"`
+	NoProxy    string `mapstructure:"no_proxy"`
+
+	// Local PEM file with the CA certificates trusted when downloading goss.
+	// It is uploaded to remote_path and passed to curl or wget.
+	CABundle string `mapstructure:"ca_bundle"`
+
+	// Minisign public key goss is verified with, the base64 key or the content
+	// of a minisign .pub file. When set, goss is downloaded on the local machine,
+	// verified and uploaded to download_path.
+	SignaturePublicKey string `mapstructure:"signature_public_key"`
+
+	// URL of the detached minisign signature of goss.
+	// This defaults to url followed by .minisig
+	SignatureURL string `mapstructure:"signature_url"`
+
 	// The --gossfile flag
 	GossFile string `mapstructure:"goss_file"`
 
@@ -70,34 +160,122 @@ type GossConfig struct {
 
 	// The remote folder where the goss tests will be uploaded to.
 	// This should be set to a pre-existing directory, it defaults to /tmp
+	// or C:/Windows/Temp on Windows
 	RemoteFolder string `mapstructure:"remote_folder"`
 
 	// The remote path where the goss tests will be uploaded.
 	// This defaults to remote_folder/goss
 	RemotePath string `mapstructure:"remote_path"`
 
+	// Keep the directory structure of the tests relative to base_dir
+	// below remote_path instead of uploading every entry by its base name.
+	PreservePaths bool `mapstructure:"preserve_paths"`
+
+	// The local directory paths are kept relative to when preserve_paths is set.
+	// This defaults to the current working directory
+	BaseDir string `mapstructure:"base_dir"`
+
+	// Upload all tests as a single archive and extract it on the remote host.
+	// Falls back to uploading file by file when tar or Expand-Archive is missing.
+	ArchiveUpload bool `mapstructure:"archive_upload"`
+
+	// Skip the local check of the goss spec files for syntax errors
+	// and unknown resource types
+	SkipLint bool `mapstructure:"skip_lint"`
+
 	// Should be download of spec file and debug info be skipped
 	SkipDownload bool `mapstructure:"skip_download"`
 
-	// The format to use for test output
+	// Local rendered spec the freshly rendered spec is compared against,
+	// e.g. a goss-spec.yaml of an earlier build committed with the tests
+	BaselineSpec string `mapstructure:"baseline_spec"`
+
+	// What happens when the rendered spec drifted from baseline_spec
+	// Available: [fail warn]
+	// Default:   fail
+	OnDrift string `mapstructure:"on_drift"`
+
+	// Commands run on the remote host when a goss phase fails.
+	// Their output is written to the local goss-diagnostics directory
+	OnFailureCommands []string `mapstructure:"on_failure_commands"`
+
+	// Remote files downloaded to goss-diagnostics when a goss phase fails
+	OnFailureFiles []string `mapstructure:"on_failure_files"`
+
+	// Print the remote plan instead of running it. Every step of Provision is
+	// gone through, but no command is run and no file transferred.
+	// Also enabled by setting PACKER_GOSS_DRY_RUN
+	DryRun bool `mapstructure:"dry_run"`
+
+	// Local file the plan is written to as JSON during a dry run
+	DryRunFile string `mapstructure:"dry_run_file"`
+
+	// Where goss is staged when the directory of download_path is mounted noexec.
+	// Only used on Linux and macOS, it defaults to /var/tmp
+	ExecFolder string `mapstructure:"exec_folder"`
+
+	// The formats to use for test output
 	// Available: [documentation json json_oneline junit nagios nagios_verbose rspecish silent tap]
 	// Default:   rspecish
-	Format string `mapstructure:"format"`
+	// The first format is printed to the log, the results in the other formats
+	// are saved to goss-results-FORMAT files in the current directory
+	Format []string `mapstructure:"format"`
 
 	// The format options to use for printing test output
 	// Available: [perfdata verbose pretty]
-	// Default:   verbose
-	FormatOptions string `mapstructure:"format_options"`
+	// Each option is only passed to the formats supporting it
+	FormatOptions []string `mapstructure:"format_options"`
+
+	// Keep goss and the rendered spec in the image once the tests passed,
+	// e.g. for goss serve health endpoints of running instances
+	Persist *PersistConfig `mapstructure:"persist"`
 
 	ctx interpolate.Context
 }
 
+// PersistConfig is the persist block
+type PersistConfig struct {
+	// Where goss is installed to.
+	// This defaults to /usr/local/bin/goss, or C:/Program Files/goss/goss.exe on Windows
+	BinaryPath string `mapstructure:"binary_path"`
+
+	// Where the rendered spec is copied to.
+	// This defaults to /etc/goss/goss.yaml, or C:/ProgramData/goss/goss.yaml on Windows
+	SpecPath string `mapstructure:"spec_path"`
+
+	// Install a systemd unit, or a scheduled task on Windows, running goss serve at boot
+	Service bool `mapstructure:"service"`
+
+	// The port goss serve listens on, it defaults to 8080
+	Port int `mapstructure:"port"`
+}
+
 var validFormats = []string{"documentation", "json", "json_oneline", "junit", "nagios", "nagios_verbose", "rspecish", "silent", "tap"}
 var validFormatOptions = []string{"perfdata", "verbose", "pretty"}
 
+// formatOptionFormats are the formats supporting each format option
+var formatOptionFormats = map[string][]string{
+	"perfdata": {"nagios", "nagios_verbose"},
+	"verbose":  {"nagios", "nagios_verbose"},
+	"pretty":   {"json"},
+}
+
 // Provisioner implements a packer Provisioner
 type Provisioner struct {
 	config GossConfig
+
+	// The communicator type of the running build, e.g. ssh or winrm
+	connType string
+
+	communicator  packer.Communicator
+	generatedData map[string]interface{}
+
+	// Durations of the phases of Provision and the validate results, for metrics_file
+	durations map[string]time.Duration
+	results   *gossResults
+
+	// The goss phases that exited with a non-zero status inspect mode let through
+	failedPhases map[string]bool
 }
 
 func (p *Provisioner) ConfigSpec() hcldec.ObjectSpec {
@@ -110,7 +288,8 @@ func (p *Provisioner) Prepare(raws ...interface{}) error {
 		Interpolate:        true,
 		InterpolateContext: &p.config.ctx,
 		InterpolateFilter: &interpolate.RenderFilter{
-			Exclude: []string{},
+			// Rendered by renderURL once the defaults are known
+			Exclude: []string{"url"},
 		},
 	}, raws...)
 	if err != nil {
@@ -135,42 +314,30 @@ func (p *Provisioner) Prepare(raws ...interface{}) error {
 			return err
 		}
 		p.config.URL = url
+	} else {
+		url, err := p.renderURL()
+		if err != nil {
+			return err
+		}
+		p.config.URL = url
 	}
 
 	if p.config.DownloadPath == "" {
-		os := strings.ToLower(p.config.TargetOs)
-		if p.config.URL == "" {
-			p.config.DownloadPath = fmt.Sprintf("/tmp/goss-%s-%s-%s", p.config.Version, os, p.config.Arch)
-		} else {
-			list := strings.Split(p.config.URL, "/")
-
-			file := strings.Split(list[len(list)-1], "-")
-			arch := file[2]
-
-			b, err := p.lessThan(4)
-			if err != nil {
-				return err
-			}
-
-			if p.isGossAlpha() && b {
-				// The format of the alpha files includes an additional entry
-				// ex: goss-alpha-windows-amd64.exe
-				arch = file[3]
-			}
-
-			version := strings.TrimPrefix(list[len(list)-2], "v")
-			p.config.DownloadPath = fmt.Sprintf("/tmp/goss-%s-%s-%s", version, os, arch)
-		}
+		p.config.DownloadPath = p.defaultDownloadPath()
 	}
 
 	if p.config.RemoteFolder == "" {
-		p.config.RemoteFolder = "/tmp"
+		p.config.RemoteFolder = p.tempFolder()
 	}
 
 	if p.config.RemotePath == "" {
 		p.config.RemotePath = fmt.Sprintf("%s/goss", p.config.RemoteFolder)
 	}
 
+	if p.config.PreservePaths && p.config.BaseDir == "" {
+		p.config.BaseDir = "."
+	}
+
 	if p.config.Tests == nil {
 		p.config.Tests = make([]string, 0)
 	}
@@ -180,41 +347,128 @@ func (p *Provisioner) Prepare(raws ...interface{}) error {
 	}
 
 	var errs *packer.MultiError
-	if p.config.Format != "" {
-		valid := false
-		for _, candidate := range validFormats {
-			if p.config.Format == candidate {
-				valid = true
-				break
-			}
+	if p.config.RetryTimeout != "" {
+		if _, err := time.ParseDuration(p.config.RetryTimeout); err != nil {
+			errs = packer.MultiErrorAppend(errs,
+				fmt.Errorf("Invalid retry_timeout %s: %s", p.config.RetryTimeout, err))
+		}
+	}
+
+	if p.config.Sleep != "" {
+		if _, err := time.ParseDuration(p.config.Sleep); err != nil {
+			errs = packer.MultiErrorAppend(errs,
+				fmt.Errorf("Invalid sleep %s: %s", p.config.Sleep, err))
 		}
-		if !valid {
+	}
+
+	if p.config.MaxRetryAttempts < 0 {
+		errs = packer.MultiErrorAppend(errs,
+			fmt.Errorf("max_retry_attempts must not be negative"))
+	}
+
+	seenFormats := make(map[string]bool)
+	for i, format := range p.config.Format {
+		if !oneOf(format, validFormats) {
 			errs = packer.MultiErrorAppend(errs,
 				fmt.Errorf("Invalid format choice %s. Valid options: %v",
-					p.config.Format, validFormats))
+					format, validFormats))
+		}
+		if seenFormats[format] {
+			errs = packer.MultiErrorAppend(errs,
+				fmt.Errorf("format %s is listed more than once", format))
+		}
+		seenFormats[format] = true
+		if i > 0 && format == "silent" {
+			errs = packer.MultiErrorAppend(errs,
+				fmt.Errorf("format silent has no results to save, it can only be the first format"))
 		}
 	}
 
-	if p.config.FormatOptions != "" {
-		valid := false
-		for _, candidate := range validFormatOptions {
-			if p.config.FormatOptions == candidate {
-				valid = true
+	for severity, action := range p.config.SeverityPolicy {
+		if !oneOf(action, validSeverityActions) {
+			errs = packer.MultiErrorAppend(errs,
+				fmt.Errorf("Invalid severity_policy action %s for %s. Valid options: %v",
+					action, severity, validSeverityActions))
+		}
+	}
+
+	if p.config.Attestation {
+		if p.config.AttestationKey == "" {
+			errs = packer.MultiErrorAppend(errs,
+				fmt.Errorf("attestation requires attestation_key"))
+		} else if _, err := readAttestationKey(p.config.AttestationKey); err != nil {
+			errs = packer.MultiErrorAppend(errs,
+				fmt.Errorf("Bad attestation_key '%s': %s", p.config.AttestationKey, err))
+		}
+	}
+
+	for _, option := range p.config.FormatOptions {
+		if !oneOf(option, validFormatOptions) {
+			errs = packer.MultiErrorAppend(errs,
+				fmt.Errorf("Invalid format options choice %s. Valid options: %v",
+					option, validFormatOptions))
+			continue
+		}
+
+		supported := false
+		for _, format := range p.formats() {
+			if supportsOption(format, option) {
+				supported = true
 				break
 			}
 		}
-		if !valid {
+		if !supported {
 			errs = packer.MultiErrorAppend(errs,
-				fmt.Errorf("Invalid format options choice %s. Valid options: %v",
-					p.config.FormatOptions, validFormatOptions))
+				fmt.Errorf("Format option %s isn't supported by %s, only by %v",
+					option, strings.Join(p.formats(), ", "), formatOptionFormats[option]))
 		}
 	}
 
-	if len(p.config.Tests) == 0 {
+	if p.config.Mode != "" {
+		if !oneOf(p.config.Mode, validModes) {
+			errs = packer.MultiErrorAppend(errs,
+				fmt.Errorf("Invalid mode choice %s. Valid options: %v",
+					p.config.Mode, validModes))
+		}
+	}
+
+	if p.config.OnDrift != "" {
+		if !oneOf(p.config.OnDrift, validDriftActions) {
+			errs = packer.MultiErrorAppend(errs,
+				fmt.Errorf("Invalid on_drift choice %s. Valid options: %v",
+					p.config.OnDrift, validDriftActions))
+		}
+	}
+
+	if p.config.BaselineSpec != "" {
+		if _, err := os.Stat(p.config.BaselineSpec); err != nil {
+			errs = packer.MultiErrorAppend(errs,
+				fmt.Errorf("Bad baseline_spec '%s': %s", p.config.BaselineSpec, err))
+		}
+	}
+
+	if p.config.Persist != nil {
+		for _, err := range p.checkPersist() {
+			errs = packer.MultiErrorAppend(errs, err)
+		}
+	}
+
+	if p.generating() {
+		for _, err := range p.checkGenerate() {
+			errs = packer.MultiErrorAppend(errs, err)
+		}
+	} else if len(p.config.Tests) == 0 {
 		errs = packer.MultiErrorAppend(errs,
 			errors.New("tests must be specified"))
 	}
 
+	tests, err := p.resolveTests()
+	if err != nil {
+		errs = packer.MultiErrorAppend(errs, err)
+	} else {
+		p.config.Tests = tests
+	}
+
 	for _, path := range p.config.Tests {
 		if _, err := os.Stat(path); err != nil {
 			errs = packer.MultiErrorAppend(errs,
@@ -222,9 +476,79 @@ func (p *Provisioner) Prepare(raws ...interface{}) error {
 		}
 	}
 
-	if p.config.TargetOs != linux && p.config.TargetOs != windows {
+	if errs == nil || len(errs.Errors) == 0 {
+		if err := p.checkDestinations(); err != nil {
+			errs = packer.MultiErrorAppend(errs, err)
+		}
+	}
+
+	if !p.config.SkipLint && (errs == nil || len(errs.Errors) == 0) {
+		if err := p.lintSpecs(); err != nil {
+			errs = packer.MultiErrorAppend(errs, err)
+		}
+	}
+
+	if p.config.TargetOs != linux && p.config.TargetOs != windows && p.config.TargetOs != darwin {
+		errs = packer.MultiErrorAppend(errs,
+			fmt.Errorf("Os must be %s, %s or %s", linux, windows, darwin))
+	}
+
+	if p.config.Shell != "" {
+		if !oneOf(p.config.Shell, validShells) {
+			errs = packer.MultiErrorAppend(errs,
+				fmt.Errorf("Invalid shell choice %s. Valid options: %v",
+					p.config.Shell, validShells))
+		}
+	}
+
+	for _, proxy := range []string{p.config.HTTPProxy, p.config.HTTPSProxy} {
+		if proxy == "" {
+			continue
+		}
+		if u, err := url.Parse(proxy); err != nil || u.Scheme == "" || u.Host == "" {
+			errs = packer.MultiErrorAppend(errs,
+				fmt.Errorf("Invalid proxy %s: must be a URL like http://proxy:3128", proxy))
+		}
+	}
+
+	if p.config.SignaturePublicKey != "" {
+		if _, err := parseMinisignKey(p.config.SignaturePublicKey); err != nil {
+			errs = packer.MultiErrorAppend(errs,
+				fmt.Errorf("Invalid signature_public_key: %s", err))
+		}
+	} else if p.config.SignatureURL != "" {
+		errs = packer.MultiErrorAppend(errs,
+			fmt.Errorf("signature_url requires signature_public_key"))
+	}
+
+	if p.config.CABundle != "" {
+		if _, err := os.Stat(p.config.CABundle); err != nil {
+			errs = packer.MultiErrorAppend(errs,
+				fmt.Errorf("Bad ca_bundle '%s': %s", p.config.CABundle, err))
+		}
+		if p.config.SkipSSLChk {
+			errs = packer.MultiErrorAppend(errs,
+				errors.New("skip_ssl can't be used together with ca_bundle"))
+		}
+	}
+
+	if p.config.ElevatedPassword != "" && p.config.ElevatedUser == "" {
 		errs = packer.MultiErrorAppend(errs,
-			fmt.Errorf("Os must be %s or %s", linux, windows))
+			errors.New("elevated_user must be specified with elevated_password"))
+	}
+
+	if p.config.ElevatedUser != "" && p.config.TargetOs != windows {
+		errs = packer.MultiErrorAppend(errs,
+			fmt.Errorf("elevated_user is only supported for %s", windows))
+	}
+
+	if p.config.TargetOs == darwin && !p.isGossAlpha() {
+		// goss only published alpha builds for macOS before 0.4
+		if b, err := p.lessThan(4); err == nil && b {
+			errs = packer.MultiErrorAppend(errs,
+				fmt.Errorf("Goss %s only has alpha builds for %s, vars_env must include GOSS_USE_ALPHA=1",
+					p.config.Version, darwin))
+		}
 	}
 
 	if errs != nil && len(errs.Errors) > 0 {
@@ -235,38 +559,66 @@ func (p *Provisioner) Prepare(raws ...interface{}) error {
 }
 
 // Provision runs the Goss Provisioner
-func (p *Provisioner) Provision(ctx context.Context, ui packer.Ui, comm packer.Communicator, generatedData map[string]interface{}) error {
+func (p *Provisioner) Provision(ctx context.Context, ui packer.Ui, comm packer.Communicator, generatedData map[string]interface{}) (err error) {
 	ui.Say("Provisioning with Goss")
 	ui.Say(fmt.Sprintf("Configured to run on %s", string(p.config.TargetOs)))
 
+	if p.dryRun() {
+		ui.Say("Dry run: printing the remote plan, nothing is run on the remote host")
+		plan := p.newDryRunPlan(ui)
+		comm = plan
+		if p.config.DryRunFile != "" {
+			// The plan is written up to the step that failed as well
+			defer func() {
+				ui.Message(fmt.Sprintf("Writing the plan to %s", p.config.DryRunFile))
+				if writeErr := plan.writeJSON(p.config.DryRunFile); writeErr != nil && err == nil {
+					err = fmt.Errorf("Error writing dry run plan: %s", writeErr)
+				}
+			}()
+		}
+	} else if p.config.MetricsFile != "" {
+		defer func() { p.writeMetrics(ui, err == nil) }()
+	}
+
+	p.communicator = comm
+	p.generatedData = generatedData
+	if connType, ok := generatedData["ConnType"].(string); ok {
+		p.connType = connType
+	}
+	ui.Message(fmt.Sprintf("Running remote commands with %s", p.shell()))
+
+	if p.config.SkipSSLChk {
+		ui.Error("Warning: skip_ssl is deprecated and disables certificate verification, use ca_bundle instead")
+	}
+
 	// For Windows need to create the target directory before download
 	if err := p.createDir(ui, comm, p.config.RemotePath); err != nil {
 		return fmt.Errorf("Error creating remote directory: %s", err)
 	}
 
 	if !p.config.SkipInstall {
-		if err := p.installGoss(ui, comm); err != nil {
+		err := p.timed("install", func() error {
+			if err := p.ensureExecutable(ui, comm); err != nil {
+				return err
+			}
+			return p.installGoss(ui, comm)
+		})
+		if err != nil {
 			return fmt.Errorf("Error installing Goss: %s", err)
 		}
 	} else {
 		ui.Message("Skipping Goss installation")
 	}
 
-	ui.Say("Uploading goss tests...")
-	if p.config.VarsFile != "" {
-		vf, err := os.Stat(p.config.VarsFile)
-		if err != nil {
-			return fmt.Errorf("Error stating file: %s", err)
-		}
-		if vf.Mode().IsRegular() {
-			ui.Message(fmt.Sprintf("Uploading vars file %s", p.config.VarsFile))
-			varsDest := filepath.ToSlash(filepath.Join(p.config.RemotePath, filepath.Base(p.config.VarsFile)))
-			if err := p.uploadFile(ui, comm, varsDest, p.config.VarsFile); err != nil {
-				return fmt.Errorf("Error uploading vars file: %s", err)
-			}
+	if p.generating() {
+		ui.Say("Generating goss spec...")
+		if err := p.generateSpec(ui, comm); err != nil {
+			return fmt.Errorf("Error generating Goss spec: %s", err)
 		}
+		return nil
 	}
 
+	ui.Say("Uploading goss tests...")
 	if len(p.config.VarsInline) != 0 {
 		ui.Message(fmt.Sprintf("Inline variables are %s", p.inline_vars()))
 	}
@@ -275,33 +627,34 @@ func (p *Provisioner) Provision(ctx context.Context, ui packer.Ui, comm packer.C
 		ui.Message(fmt.Sprintf("Env variables are %s", p.envVars()))
 	}
 
-	for _, src := range p.config.Tests {
-		s, err := os.Stat(src)
-		if err != nil {
-			return fmt.Errorf("Error stating file: %s", err)
+	err = p.timed("upload", func() error {
+		archived := false
+		if p.config.ArchiveUpload {
+			var err error
+			if archived, err = p.uploadArchive(ui, comm); err != nil {
+				return fmt.Errorf("Error uploading goss tests archive: %s", err)
+			}
 		}
 
-		if s.Mode().IsRegular() {
-			ui.Message(fmt.Sprintf("Uploading %s", src))
-			dst := filepath.ToSlash(filepath.Join(p.config.RemotePath, filepath.Base(src)))
-			if err := p.uploadFile(ui, comm, dst, src); err != nil {
-				return fmt.Errorf("Error uploading goss test: %s", err)
-			}
-		} else if s.Mode().IsDir() {
-			ui.Message(fmt.Sprintf("Uploading Dir %s", src))
-			dst := filepath.ToSlash(filepath.Join(p.config.RemotePath, filepath.Base(src)))
-			if err := p.uploadDir(ui, comm, dst, src); err != nil {
-				return fmt.Errorf("Error uploading goss test: %s", err)
-			}
-		} else {
-			ui.Message(fmt.Sprintf("Ignoring %s... not a regular file", src))
+		if !archived {
+			return p.uploadTests(ui, comm)
 		}
+		return nil
+	})
+	if err != nil {
+		return err
 	}
 
 	ui.Say("\n\n\nRunning goss tests...")
 	if err := p.runGoss(ui, comm); err != nil {
+		p.collectDiagnostics(ui, comm)
+		p.writeReproduceScript(ui)
 		return fmt.Errorf("Error running Goss: %s", err)
 	}
+	if len(p.failedPhases) != 0 {
+		// Inspect mode let the failure through, the instance may still be torn down
+		p.collectDiagnostics(ui, comm)
+	}
 
 	if !p.config.SkipDownload {
 		ui.Say("\n\n\nDownloading spec file and debug info")
@@ -312,23 +665,81 @@ func (p *Provisioner) Provision(ctx context.Context, ui packer.Ui, comm packer.C
 		ui.Message("Skipping Goss spec file and debug info download")
 	}
 
+	if p.config.Persist != nil && !p.passed() {
+		ui.Say("Goss didn't pass, not persisting goss and the rendered spec in the image")
+	} else if p.config.Persist != nil {
+		ui.Say("Persisting goss and the rendered spec in the image")
+		if err := p.persist(ui, comm); err != nil {
+			return fmt.Errorf("Error persisting Goss: %s", err)
+		}
+	}
+
+	if p.config.Attestation {
+		ui.Say("Signing validation attestation")
+		if err := p.writeAttestation(ui, comm); err != nil {
+			return fmt.Errorf("Error writing attestation: %s", err)
+		}
+	}
+
 	return nil
 }
 
-// downloadSpecs downloads the Goss specs from the remote host to current working dir on local machine
-func (p *Provisioner) downloadSpecs(ui packer.Ui, comm packer.Communicator) error {
-	ui.Message(fmt.Sprintf("Downloading Goss specs from, %s and %s to current dir", gossSpecFile, gossDebugSpecFile))
-	for _, file := range []string{gossSpecFile, gossDebugSpecFile} {
-		f, err := os.Create(filepath.Base(file))
+// uploadTests uploads the vars file and the goss tests one by one
+func (p *Provisioner) uploadTests(ui packer.Ui, comm packer.Communicator) error {
+	if p.config.VarsFile != "" {
+		vf, err := os.Stat(p.config.VarsFile)
+		if err != nil {
+			return fmt.Errorf("Error stating file: %s", err)
+		}
+		if vf.Mode().IsRegular() {
+			ui.Message(fmt.Sprintf("Uploading vars file %s", p.config.VarsFile))
+			varsDest, err := p.remoteTestPath(p.config.VarsFile)
+			if err != nil {
+				return fmt.Errorf("Error uploading vars file: %s", err)
+			}
+			if err := p.uploadTestFile(ui, comm, varsDest, p.config.VarsFile); err != nil {
+				return fmt.Errorf("Error uploading vars file: %s", err)
+			}
+		}
+	}
+
+	for _, src := range p.config.Tests {
+		s, err := os.Stat(src)
+		if err != nil {
+			return fmt.Errorf("Error stating file: %s", err)
+		}
+
+		dst, err := p.remoteTestPath(src)
 		if err != nil {
-			return fmt.Errorf("Error opening: %s", err)
+			return fmt.Errorf("Error uploading goss test: %s", err)
 		}
 
-		if err = comm.Download(file, f); err != nil {
-			_ = f.Close()
-			return fmt.Errorf("Error downloading %s: %s", file, err)
+		if p.excluded(filepath.Base(src)) {
+			ui.Message(fmt.Sprintf("Excluding %s", src))
+		} else if s.Mode().IsRegular() {
+			ui.Message(fmt.Sprintf("Uploading %s", src))
+			if err := p.uploadTestFile(ui, comm, dst, src); err != nil {
+				return fmt.Errorf("Error uploading goss test: %s", err)
+			}
+		} else if s.Mode().IsDir() {
+			ui.Message(fmt.Sprintf("Uploading Dir %s", src))
+			if err := p.uploadDir(ui, comm, dst, src); err != nil {
+				return fmt.Errorf("Error uploading goss test: %s", err)
+			}
+		} else {
+			ui.Message(fmt.Sprintf("Ignoring %s... not a regular file", src))
+		}
+	}
+	return nil
+}
+
+// downloadSpecs downloads the Goss specs from the remote host to current working dir on local machine
+func (p *Provisioner) downloadSpecs(ui packer.Ui, comm packer.Communicator) error {
+	ui.Message(fmt.Sprintf("Downloading Goss specs from, %s and %s to current dir", p.specFile(), p.debugSpecFile()))
+	for _, file := range []string{p.specFile(), p.debugSpecFile()} {
+		if err := p.downloadFile(comm, file, filepath.Base(file)); err != nil {
+			return err
 		}
-		_ = f.Close()
 	}
 	return nil
 }
@@ -338,70 +749,219 @@ func (p *Provisioner) installGoss(ui packer.Ui, comm packer.Communicator) error
 	ui.Message(fmt.Sprintf("Installing Goss from, %s", p.config.URL))
 	ctx := context.TODO()
 
+	if p.config.SignaturePublicKey != "" {
+		if err := p.installVerified(ui, comm); err != nil {
+			return err
+		}
+	} else if err := p.downloadGoss(ui, comm); err != nil {
+		return err
+	}
+
 	cmd := &packer.RemoteCmd{
-		// Fallback on wget if curl failed for any reason (such as not being installed)
-		Command: fmt.Sprintf(
-			"curl -sL %s %s -o %s %s || wget -q %s %s -O %s %s",
-			p.sslFlag("curl"), p.userPass("curl"), p.config.DownloadPath, p.config.URL,
-			p.sslFlag("wget"), p.userPass("wget"), p.config.DownloadPath, p.config.URL),
+		Command: p.dialect().install(p.config.DownloadPath),
 	}
-	ui.Message(fmt.Sprintf("Downloading Goss to %s", p.config.DownloadPath))
 	if err := cmd.RunWithUi(ctx, comm, ui); err != nil {
-		return fmt.Errorf("Unable to download Goss: %s", err)
+		return fmt.Errorf("Unable to install Goss: %s", err)
 	}
-	cmd = &packer.RemoteCmd{
-		Command: fmt.Sprintf("chmod 555 %s && %s --version", p.config.DownloadPath, p.config.DownloadPath),
+
+	return nil
+}
+
+// downloadGoss downloads goss on the remote host. The CA bundle is only
+// needed by the download and removed right after it, it isn't left in the image.
+func (p *Provisioner) downloadGoss(ui packer.Ui, comm packer.Communicator) (err error) {
+	ctx := context.TODO()
+	if p.config.CABundle != "" {
+		ui.Message(fmt.Sprintf("Uploading CA bundle %s", p.config.CABundle))
+		if err := p.uploadFile(ui, comm, p.caBundlePath(), p.config.CABundle); err != nil {
+			return fmt.Errorf("Unable to upload CA bundle: %s", err)
+		}
+		defer func() {
+			cmd := &packer.RemoteCmd{Command: p.dialect().remove(p.caBundlePath())}
+			rerr := cmd.RunWithUi(ctx, comm, ui)
+			if rerr == nil && cmd.ExitStatus() != 0 {
+				rerr = fmt.Errorf("exit status %d", cmd.ExitStatus())
+			}
+			if rerr != nil && err == nil {
+				err = fmt.Errorf("Unable to remove CA bundle: %s", rerr)
+			}
+		}()
 	}
+
+	cmd := &packer.RemoteCmd{
+		Command: p.downloadCmd(),
+	}
+	ui.Message(fmt.Sprintf("Downloading Goss to %s", p.config.DownloadPath))
 	if err := cmd.RunWithUi(ctx, comm, ui); err != nil {
-		return fmt.Errorf("Unable to install Goss: %s", err)
+		return fmt.Errorf("Unable to download Goss: %s", err)
 	}
-
 	return nil
 }
 
-// runGoss makes test and render goss commands and passes them to executor func runGossCmd
-func (p *Provisioner) runGoss(ui packer.Ui, comm packer.Communicator) error {
+// downloadCmd makes the command downloading goss to download_path
+func (p *Provisioner) downloadCmd() string {
+	return p.dialect().download(p.dialect().envVars(p.proxyEnv()), p.config.URL, p.config.DownloadPath,
+		fmt.Sprintf("%s %s %s", p.sslFlag("curl"), p.caFlag("curl"), p.userPass("curl")),
+		fmt.Sprintf("%s %s %s", p.sslFlag("wget"), p.caFlag("wget"), p.userPass("wget")))
+}
+
+// gossPhases are the goss commands in the order they run
+var gossPhases = []string{"render", "render debug", "validate"}
+
+// gossCmds makes the test and render goss commands, keyed by phase
+func (p *Provisioner) gossCmds() map[string]string {
 	goss := fmt.Sprintf("%s", p.config.DownloadPath)
+	d := p.dialect()
+	args := fmt.Sprintf("%s %s %s", p.config.GossFile, p.vars(), p.inline_vars())
 
 	cmdMap := map[string]string{
-		"render": fmt.Sprintf("cd %s && %s %s %s %s %s render > %s",
-			p.config.RemotePath, p.envVars(), goss, p.config.GossFile,
-			p.vars(), p.inline_vars(), gossSpecFile,
+		"render": d.exec(p.config.RemotePath, p.envVars(), false, goss,
+			fmt.Sprintf("%s render", args), p.specFile(),
 		),
-		"render debug": fmt.Sprintf("cd %s && %s %s %s %s %s render -d > %s",
-			p.config.RemotePath, p.envVars(), goss, p.config.GossFile,
-			p.vars(), p.inline_vars(), gossDebugSpecFile,
+		"render debug": d.exec(p.config.RemotePath, p.envVars(), false, goss,
+			fmt.Sprintf("%s render -d", args), p.debugSpecFile(),
 		),
-		"validate": fmt.Sprintf("cd %s && %s %s %s %s %s %s validate --retry-timeout %s --sleep %s %s %s",
-			p.config.RemotePath, p.enableSudo(), p.envVars(), goss, p.config.GossFile,
-			p.vars(), p.inline_vars(), p.retryTimeout(), p.sleep(), p.format(), p.formatOptions(),
+		"validate": d.exec(p.config.RemotePath, p.envVars(), p.config.UseSudo, goss,
+			fmt.Sprintf("%s validate --retry-timeout %s --sleep %s %s %s",
+				args, p.retryTimeout(), p.sleep(), p.format(), p.formatOptions(p.validateFormat())),
+			"",
 		),
 	}
+	return cmdMap
+}
 
-	for message, cmd := range cmdMap {
-		ui.Say(fmt.Sprintf("Running GOSS %s command: %s", message, cmd))
-		err := p.runGossCmd(ui, comm, &packer.RemoteCmd{Command: cmd}, message)
-		if err != nil {
-			return err
+// runGoss runs the test and render goss commands with executor func runGossCmd
+func (p *Provisioner) runGoss(ui packer.Ui, comm packer.Communicator) error {
+	cmdMap := p.gossCmds()
+
+	ui.Say(p.retryBudget())
+
+	for _, message := range gossPhases {
+		cmd := cmdMap[message]
+		attempts := 1
+		if message == "validate" {
+			attempts = p.maxRetryAttempts()
+		}
+
+		for attempt := 1; ; attempt++ {
+			ui.Say(fmt.Sprintf("Running GOSS %s command: %s", message, cmd))
+			command := cmd
+			if p.elevated() {
+				// The elevated wrapper removes itself once it ran
+				var err error
+				if command, err = p.elevatedCmd(cmd); err != nil {
+					return err
+				}
+				ui.Message(fmt.Sprintf("Running as elevated user %s", p.config.ElevatedUser))
+			}
+			// render debug counts towards render
+			err := p.timed(strings.Fields(message)[0], func() error {
+				return p.runGossCmd(ui, comm, &packer.RemoteCmd{Command: command}, message)
+			})
+			if err == nil {
+				break
+			}
+			if attempt >= attempts {
+				if message == "validate" {
+					p.saveFormats(ui)
+				}
+				return err
+			}
+			ui.Say(fmt.Sprintf("Goss %s attempt %d of %d failed: %s", message, attempt, attempts, err))
+		}
+
+		if message == "render" && p.config.BaselineSpec != "" {
+			if p.failedPhases["render"] {
+				// The rendered spec of a failed render isn't worth comparing
+				ui.Say("Skipping drift check, goss render failed")
+				continue
+			}
+			if err := p.checkDrift(ui, comm); err != nil {
+				return err
+			}
 		}
 	}
+	p.saveFormats(ui)
 	return nil
 }
 
 // runGoss tests and render goss commands.
 func (p *Provisioner) runGossCmd(ui packer.Ui, comm packer.Communicator, cmd *packer.RemoteCmd, message string) error {
 	ctx := context.TODO()
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if message == "validate" && p.jsonResults() {
+		return p.runValidateJSON(ui, comm, cmd, &stdout, &stderr)
+	}
 	if err := cmd.RunWithUi(ctx, comm, ui); err != nil {
 		return err
 	}
+	return p.checkExitStatus(ui, cmd, message, stdout.String(), stderr.String())
+}
+
+// runValidateJSON runs goss validate with the json format and prints the results
+// in the output format, so the JSON results don't take the place of the log format
+func (p *Provisioner) runValidateJSON(ui packer.Ui, comm packer.Communicator, cmd *packer.RemoteCmd,
+	stdout, stderr *bytes.Buffer) error {
+	// Don't keep the results of an earlier attempt
+	p.results = nil
+	if err := comm.Start(context.TODO(), cmd); err != nil {
+		return err
+	}
+	cmd.Wait()
+	if stderr.Len() != 0 {
+		ui.Error(stderr.String())
+	}
+
+	// Without results goss couldn't run, its output is printed as is
+	results, err := parseResults(stdout.String())
+	if err != nil {
+		if stdout.Len() != 0 {
+			ui.Message(stdout.String())
+		}
+		return p.checkExitStatus(ui, cmd, "validate", stdout.String(), stderr.String())
+	}
+
+	out, err := p.renderResults(results, p.outputFormat())
+	if err != nil {
+		return fmt.Errorf("Error rendering %s results: %s", p.outputFormat(), err)
+	}
+	if len(out) != 0 {
+		ui.Message(string(out))
+	}
+
+	p.results = results
+	if p.config.ComplianceReport {
+		if err := p.writeComplianceReports(ui, results); err != nil {
+			return fmt.Errorf("Error writing compliance report: %s", err)
+		}
+	}
+	if len(p.config.SeverityPolicy) != 0 {
+		return p.applySeverityPolicy(ui, results)
+	}
+	return p.checkExitStatus(ui, cmd, "validate", stdout.String(), stderr.String())
+}
+
+// checkExitStatus fails the goss phase message on a non-zero exit status, in inspect
+// mode only when goss couldn't run and execution errors aren't allowed
+func (p *Provisioner) checkExitStatus(ui packer.Ui, cmd *packer.RemoteCmd, message, stdout, stderr string) error {
 	if cmd.ExitStatus() != 0 {
-		// Inspect mode is on. Report failure but don't fail.
-		if p.config.Inspect {
-			ui.Say(fmt.Sprintf("Goss %s failed", message))
-			ui.Say(fmt.Sprintf("Inspect mode on : proceeding without failing Packer"))
-		} else {
+		if !p.config.Inspect {
 			return fmt.Errorf("goss non-zero exit status")
 		}
+
+		// Inspect mode is on. Report failed tests but don't fail.
+		kind := p.failureKind(message, cmd.ExitStatus(), stdout, stderr)
+		ui.Say(fmt.Sprintf("Goss %s failed, %s (exit status %d)", message, kind, cmd.ExitStatus()))
+		if kind == executionError && !p.config.AllowExecutionErrors {
+			return fmt.Errorf("goss %s couldn't run, set allow_execution_errors to proceed in inspect mode", message)
+		}
+		if p.failedPhases == nil {
+			p.failedPhases = make(map[string]bool)
+		}
+		p.failedPhases[message] = true
+		ui.Say(fmt.Sprintf("Inspect mode on : proceeding without failing Packer"))
 	} else {
 		ui.Say(fmt.Sprintf("Goss %s ran successfully", message))
 	}
@@ -422,23 +982,62 @@ func (p *Provisioner) sleep() string {
 	return p.config.Sleep
 }
 
+func (p *Provisioner) maxRetryAttempts() int {
+	if p.config.MaxRetryAttempts < 1 {
+		return 1
+	}
+	return p.config.MaxRetryAttempts
+}
+
+// retryBudget describes how long goss validate may retry at most
+func (p *Provisioner) retryBudget() string {
+	attempts := p.maxRetryAttempts()
+	budget := fmt.Sprintf("Goss retry budget: %d attempt(s), each retrying for up to %s with %s sleep",
+		attempts, p.retryTimeout(), p.sleep())
+
+	if timeout, err := time.ParseDuration(p.retryTimeout()); err == nil {
+		budget += fmt.Sprintf(" (%s in total)", time.Duration(attempts)*timeout)
+	}
+	return budget
+}
+
 func (p *Provisioner) format() string {
-	if p.config.Format != "" {
-		return fmt.Sprintf("-f %s", p.config.Format)
+	if len(p.config.Format) != 0 || p.jsonResults() {
+		return fmt.Sprintf("-f %s", p.validateFormat())
 	}
 	return ""
 }
 
-func (p *Provisioner) formatOptions() string {
-	if p.config.FormatOptions != "" {
-		return fmt.Sprintf("-o %s", p.config.FormatOptions)
+// formatOptions returns an -o flag for each format option supported by format
+func (p *Provisioner) formatOptions(format string) string {
+	var flags []string
+	for _, option := range p.config.FormatOptions {
+		if supportsOption(format, option) {
+			flags = append(flags, fmt.Sprintf("-o %s", option))
+		}
 	}
-	return ""
+	return strings.Join(flags, " ")
+}
+
+// supportsOption reports whether format supports the format option
+func supportsOption(format, option string) bool {
+	return oneOf(format, formatOptionFormats[option])
+}
+
+// oneOf reports whether value is one of allowed
+func oneOf(value string, allowed []string) bool {
+	for _, candidate := range allowed {
+		if value == candidate {
+			return true
+		}
+	}
+	return false
 }
 
 func (p *Provisioner) vars() string {
 	if p.config.VarsFile != "" {
-		return fmt.Sprintf("--vars %s", filepath.ToSlash(filepath.Join(p.config.RemotePath, filepath.Base(p.config.VarsFile))))
+		varsDest, _ := p.remoteTestPath(p.config.VarsFile)
+		return fmt.Sprintf("--vars %s", varsDest)
 	}
 	return ""
 }
@@ -447,15 +1046,9 @@ func (p *Provisioner) inline_vars() string {
 	if len(p.config.VarsInline) != 0 {
 		inlineVarsJson, err := json.Marshal(p.config.VarsInline)
 		if err == nil {
-			switch p.config.TargetOs {
-			case windows:
-				// don't include single quotes around the json string and replace " with ' otherwise the variables are not recognised
-				return fmt.Sprintf("--vars-inline %s", strings.Replace(string(inlineVarsJson), "\"", "'", -1))
-			default:
-				return fmt.Sprintf("--vars-inline '%s'", string(inlineVarsJson))
-			}
+			return p.dialect().inlineVars(string(inlineVarsJson))
 		} else {
-			fmt.Errorf("Error converting inline vars to json string %v", err)
+			log.Printf("Error converting inline vars to json string %v", err)
 		}
 	}
 	return ""
@@ -494,18 +1087,7 @@ func (p *Provisioner) lessThan(minor int) (bool, error) {
 }
 
 func (p *Provisioner) envVars() string {
-	var sb strings.Builder
-	for env_var, value := range p.config.VarsEnv {
-		switch p.config.TargetOs {
-		case windows:
-			// Windows requires a call to "set" as separate command seperated by && for each env variable
-			sb.WriteString(fmt.Sprintf("set \"%s=%s\" && ", env_var, value))
-		default:
-			sb.WriteString(fmt.Sprintf("%s=\"%s\" ", env_var, value))
-		}
-
-	}
-	return sb.String()
+	return p.dialect().envVars(p.config.VarsEnv)
 }
 
 func (p *Provisioner) sslFlag(cmdType string) string {
@@ -522,14 +1104,41 @@ func (p *Provisioner) sslFlag(cmdType string) string {
 	return ""
 }
 
-// enable sudo if required
-func (p *Provisioner) enableSudo() string {
-	if p.config.UseSudo {
-		return "sudo"
+// Trust the uploaded CA bundle
+func (p *Provisioner) caFlag(cmdType string) string {
+	if p.config.CABundle != "" {
+		switch cmdType {
+		case "curl":
+			return fmt.Sprintf("--cacert %s", p.caBundlePath())
+		case "wget":
+			return fmt.Sprintf("--ca-certificate=%s", p.caBundlePath())
+		default:
+			return ""
+		}
 	}
 	return ""
 }
 
+// caBundlePath returns the remote path the CA bundle is uploaded to
+func (p *Provisioner) caBundlePath() string {
+	return path.Join(filepath.ToSlash(p.config.RemotePath), caBundleFile)
+}
+
+// proxyEnv returns the proxy environment variables used to download goss
+func (p *Provisioner) proxyEnv() map[string]string {
+	env := make(map[string]string)
+	if p.config.HTTPProxy != "" {
+		env["http_proxy"] = p.config.HTTPProxy
+	}
+	if p.config.HTTPSProxy != "" {
+		env["https_proxy"] = p.config.HTTPSProxy
+	}
+	if p.config.NoProxy != "" {
+		env["no_proxy"] = p.config.NoProxy
+	}
+	return env
+}
+
 // Deal with curl & wget username and password
 func (p *Provisioner) userPass(cmdType string) string {
 	if p.config.Username != "" {
@@ -569,12 +1178,7 @@ func (p *Provisioner) createDir(ui packer.Ui, comm packer.Communicator, dir stri
 }
 
 func (p *Provisioner) mkDir(dir string) string {
-	switch p.config.TargetOs {
-	case windows:
-		return fmt.Sprintf("powershell /c mkdir -p '%s'", dir)
-	default:
-		return fmt.Sprintf("mkdir -p '%s'", dir)
-	}
+	return p.dialect().mkDir(dir)
 }
 
 // uploadFile uploads a file
@@ -593,6 +1197,10 @@ func (p *Provisioner) uploadFile(ui packer.Ui, comm packer.Communicator, dst, sr
 
 // uploadDir uploads a directory
 func (p *Provisioner) uploadDir(ui packer.Ui, comm packer.Communicator, dst, src string) error {
+	if len(p.config.Exclude) != 0 {
+		return p.uploadDirFiltered(ui, comm, dst, src)
+	}
+
 	var ignore []string
 	if err := p.createDir(ui, comm, dst); err != nil {
 		return err
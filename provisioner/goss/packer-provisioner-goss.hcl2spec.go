@@ -10,29 +10,70 @@ import (
 // FlatGossConfig is an auto-generated flat version of GossConfig.
 // Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
 type FlatGossConfig struct {
-	Version       *string           `cty:"version" hcl:"version"`
-	Arch          *string           `cty:"arch" hcl:"arch"`
-	URL           *string           `cty:"url" hcl:"url"`
-	DownloadPath  *string           `mapstructure:"download_path" cty:"download_path" hcl:"download_path"`
-	Username      *string           `cty:"username" hcl:"username"`
-	Password      *string           `cty:"password" hcl:"password"`
-	SkipInstall   *bool             `mapstructure:"skip_install" cty:"skip_install" hcl:"skip_install"`
-	Inspect       *bool             `cty:"inspect" hcl:"inspect"`
-	TargetOs      *string           `mapstructure:"target_os" cty:"target_os" hcl:"target_os"`
-	Tests         []string          `cty:"tests" hcl:"tests"`
-	RetryTimeout  *string           `mapstructure:"retry_timeout" cty:"retry_timeout" hcl:"retry_timeout"`
-	Sleep         *string           `mapstructure:"sleep" cty:"sleep" hcl:"sleep"`
-	UseSudo       *bool             `mapstructure:"use_sudo" cty:"use_sudo" hcl:"use_sudo"`
-	SkipSSLChk    *bool             `mapstructure:"skip_ssl" cty:"skip_ssl" hcl:"skip_ssl"`
-	GossFile      *string           `mapstructure:"goss_file" cty:"goss_file" hcl:"goss_file"`
-	VarsFile      *string           `mapstructure:"vars_file" cty:"vars_file" hcl:"vars_file"`
-	VarsInline    map[string]string `mapstructure:"vars_inline" cty:"vars_inline" hcl:"vars_inline"`
-	VarsEnv       map[string]string `mapstructure:"vars_env" cty:"vars_env" hcl:"vars_env"`
-	RemoteFolder  *string           `mapstructure:"remote_folder" cty:"remote_folder" hcl:"remote_folder"`
-	RemotePath    *string           `mapstructure:"remote_path" cty:"remote_path" hcl:"remote_path"`
-	SkipDownload  *bool             `mapstructure:"skip_download" cty:"skip_download" hcl:"skip_download"`
-	Format        *string           `mapstructure:"format" cty:"format" hcl:"format"`
-	FormatOptions *string           `mapstructure:"format_options" cty:"format_options" hcl:"format_options"`
+	PackerBuildName      *string             `mapstructure:"packer_build_name" cty:"packer_build_name" hcl:"packer_build_name"`
+	PackerBuilderType    *string             `mapstructure:"packer_builder_type" cty:"packer_builder_type" hcl:"packer_builder_type"`
+	PackerCoreVersion    *string             `mapstructure:"packer_core_version" cty:"packer_core_version" hcl:"packer_core_version"`
+	PackerDebug          *bool               `mapstructure:"packer_debug" cty:"packer_debug" hcl:"packer_debug"`
+	PackerForce          *bool               `mapstructure:"packer_force" cty:"packer_force" hcl:"packer_force"`
+	PackerOnError        *string             `mapstructure:"packer_on_error" cty:"packer_on_error" hcl:"packer_on_error"`
+	PackerUserVars       map[string]string   `mapstructure:"packer_user_variables" cty:"packer_user_variables" hcl:"packer_user_variables"`
+	PackerSensitiveVars  []string            `mapstructure:"packer_sensitive_variables" cty:"packer_sensitive_variables" hcl:"packer_sensitive_variables"`
+	Version              *string             `cty:"version" hcl:"version"`
+	Arch                 *string             `cty:"arch" hcl:"arch"`
+	URL                  *string             `cty:"url" hcl:"url"`
+	DownloadPath         *string             `mapstructure:"download_path" cty:"download_path" hcl:"download_path"`
+	Username             *string             `cty:"username" hcl:"username"`
+	Password             *string             `cty:"password" hcl:"password"`
+	SkipInstall          *bool               `mapstructure:"skip_install" cty:"skip_install" hcl:"skip_install"`
+	Inspect              *bool               `cty:"inspect" hcl:"inspect"`
+	TargetOs             *string             `mapstructure:"target_os" cty:"target_os" hcl:"target_os"`
+	Mode                 *string             `mapstructure:"mode" cty:"mode" hcl:"mode"`
+	Generate             map[string][]string `mapstructure:"generate" cty:"generate" hcl:"generate"`
+	GenerateDir          *string             `mapstructure:"generate_dir" cty:"generate_dir" hcl:"generate_dir"`
+	Tests                []string            `cty:"tests" hcl:"tests"`
+	Exclude              []string            `mapstructure:"exclude" cty:"exclude" hcl:"exclude"`
+	RetryTimeout         *string             `mapstructure:"retry_timeout" cty:"retry_timeout" hcl:"retry_timeout"`
+	Sleep                *string             `mapstructure:"sleep" cty:"sleep" hcl:"sleep"`
+	MaxRetryAttempts     *int                `mapstructure:"max_retry_attempts" cty:"max_retry_attempts" hcl:"max_retry_attempts"`
+	AllowExecutionErrors *bool               `mapstructure:"allow_execution_errors" cty:"allow_execution_errors" hcl:"allow_execution_errors"`
+	SeverityKey          *string             `mapstructure:"severity_key" cty:"severity_key" hcl:"severity_key"`
+	SeverityPolicy       map[string]string   `mapstructure:"severity_policy" cty:"severity_policy" hcl:"severity_policy"`
+	ComplianceReport     *bool               `mapstructure:"compliance_report" cty:"compliance_report" hcl:"compliance_report"`
+	MetricsFile          *string             `mapstructure:"metrics_file" cty:"metrics_file" hcl:"metrics_file"`
+	Attestation          *bool               `mapstructure:"attestation" cty:"attestation" hcl:"attestation"`
+	AttestationKey       *string             `mapstructure:"attestation_key" cty:"attestation_key" hcl:"attestation_key"`
+	UseSudo              *bool               `mapstructure:"use_sudo" cty:"use_sudo" hcl:"use_sudo"`
+	ElevatedUser         *string             `mapstructure:"elevated_user" cty:"elevated_user" hcl:"elevated_user"`
+	ElevatedPassword     *string             `mapstructure:"elevated_password" cty:"elevated_password" hcl:"elevated_password"`
+	Shell                *string             `mapstructure:"shell" cty:"shell" hcl:"shell"`
+	SkipSSLChk           *bool               `mapstructure:"skip_ssl" cty:"skip_ssl" hcl:"skip_ssl"`
+	HTTPProxy            *string             `mapstructure:"http_proxy" cty:"http_proxy" hcl:"http_proxy"`
+	HTTPSProxy           *string             `mapstructure:"https_proxy" cty:"https_proxy" hcl:"https_proxy"`
+	NoProxy              *string             `mapstructure:"no_proxy" cty:"no_proxy" hcl:"no_proxy"`
+	CABundle             *string             `mapstructure:"ca_bundle" cty:"ca_bundle" hcl:"ca_bundle"`
+	SignaturePublicKey   *string             `mapstructure:"signature_public_key" cty:"signature_public_key" hcl:"signature_public_key"`
+	SignatureURL         *string             `mapstructure:"signature_url" cty:"signature_url" hcl:"signature_url"`
+	GossFile             *string             `mapstructure:"goss_file" cty:"goss_file" hcl:"goss_file"`
+	VarsFile             *string             `mapstructure:"vars_file" cty:"vars_file" hcl:"vars_file"`
+	VarsInline           map[string]string   `mapstructure:"vars_inline" cty:"vars_inline" hcl:"vars_inline"`
+	VarsEnv              map[string]string   `mapstructure:"vars_env" cty:"vars_env" hcl:"vars_env"`
+	RemoteFolder         *string             `mapstructure:"remote_folder" cty:"remote_folder" hcl:"remote_folder"`
+	RemotePath           *string             `mapstructure:"remote_path" cty:"remote_path" hcl:"remote_path"`
+	PreservePaths        *bool               `mapstructure:"preserve_paths" cty:"preserve_paths" hcl:"preserve_paths"`
+	BaseDir              *string             `mapstructure:"base_dir" cty:"base_dir" hcl:"base_dir"`
+	ArchiveUpload        *bool               `mapstructure:"archive_upload" cty:"archive_upload" hcl:"archive_upload"`
+	SkipLint             *bool               `mapstructure:"skip_lint" cty:"skip_lint" hcl:"skip_lint"`
+	SkipDownload         *bool               `mapstructure:"skip_download" cty:"skip_download" hcl:"skip_download"`
+	BaselineSpec         *string             `mapstructure:"baseline_spec" cty:"baseline_spec" hcl:"baseline_spec"`
+	OnDrift              *string             `mapstructure:"on_drift" cty:"on_drift" hcl:"on_drift"`
+	OnFailureCommands    []string            `mapstructure:"on_failure_commands" cty:"on_failure_commands" hcl:"on_failure_commands"`
+	OnFailureFiles       []string            `mapstructure:"on_failure_files" cty:"on_failure_files" hcl:"on_failure_files"`
+	DryRun               *bool               `mapstructure:"dry_run" cty:"dry_run" hcl:"dry_run"`
+	DryRunFile           *string             `mapstructure:"dry_run_file" cty:"dry_run_file" hcl:"dry_run_file"`
+	ExecFolder           *string             `mapstructure:"exec_folder" cty:"exec_folder" hcl:"exec_folder"`
+	Format               []string            `mapstructure:"format" cty:"format" hcl:"format"`
+	FormatOptions        []string            `mapstructure:"format_options" cty:"format_options" hcl:"format_options"`
+	Persist              *FlatPersistConfig  `mapstructure:"persist" cty:"persist" hcl:"persist"`
 }
 
 // FlatMapstructure returns a new FlatGossConfig.
@@ -47,29 +88,99 @@ func (*GossConfig) FlatMapstructure() interface{ HCL2Spec() map[string]hcldec.Sp
 // The decoded values from this spec will then be applied to a FlatGossConfig.
 func (*FlatGossConfig) HCL2Spec() map[string]hcldec.Spec {
 	s := map[string]hcldec.Spec{
-		"version":        &hcldec.AttrSpec{Name: "version", Type: cty.String, Required: false},
-		"arch":           &hcldec.AttrSpec{Name: "arch", Type: cty.String, Required: false},
-		"url":            &hcldec.AttrSpec{Name: "url", Type: cty.String, Required: false},
-		"download_path":  &hcldec.AttrSpec{Name: "download_path", Type: cty.String, Required: false},
-		"username":       &hcldec.AttrSpec{Name: "username", Type: cty.String, Required: false},
-		"password":       &hcldec.AttrSpec{Name: "password", Type: cty.String, Required: false},
-		"skip_install":   &hcldec.AttrSpec{Name: "skip_install", Type: cty.Bool, Required: false},
-		"inspect":        &hcldec.AttrSpec{Name: "inspect", Type: cty.Bool, Required: false},
-		"target_os":      &hcldec.AttrSpec{Name: "target_os", Type: cty.String, Required: false},
-		"tests":          &hcldec.AttrSpec{Name: "tests", Type: cty.List(cty.String), Required: false},
-		"retry_timeout":  &hcldec.AttrSpec{Name: "retry_timeout", Type: cty.String, Required: false},
-		"sleep":          &hcldec.AttrSpec{Name: "sleep", Type: cty.String, Required: false},
-		"use_sudo":       &hcldec.AttrSpec{Name: "use_sudo", Type: cty.Bool, Required: false},
-		"skip_ssl":       &hcldec.AttrSpec{Name: "skip_ssl", Type: cty.Bool, Required: false},
-		"goss_file":      &hcldec.AttrSpec{Name: "goss_file", Type: cty.String, Required: false},
-		"vars_file":      &hcldec.AttrSpec{Name: "vars_file", Type: cty.String, Required: false},
-		"vars_inline":    &hcldec.AttrSpec{Name: "vars_inline", Type: cty.Map(cty.String), Required: false},
-		"vars_env":       &hcldec.AttrSpec{Name: "vars_env", Type: cty.Map(cty.String), Required: false},
-		"remote_folder":  &hcldec.AttrSpec{Name: "remote_folder", Type: cty.String, Required: false},
-		"remote_path":    &hcldec.AttrSpec{Name: "remote_path", Type: cty.String, Required: false},
-		"skip_download":  &hcldec.AttrSpec{Name: "skip_download", Type: cty.Bool, Required: false},
-		"format":         &hcldec.AttrSpec{Name: "format", Type: cty.String, Required: false},
-		"format_options": &hcldec.AttrSpec{Name: "format_options", Type: cty.String, Required: false},
+		"packer_build_name":          &hcldec.AttrSpec{Name: "packer_build_name", Type: cty.String, Required: false},
+		"packer_builder_type":        &hcldec.AttrSpec{Name: "packer_builder_type", Type: cty.String, Required: false},
+		"packer_core_version":        &hcldec.AttrSpec{Name: "packer_core_version", Type: cty.String, Required: false},
+		"packer_debug":               &hcldec.AttrSpec{Name: "packer_debug", Type: cty.Bool, Required: false},
+		"packer_force":               &hcldec.AttrSpec{Name: "packer_force", Type: cty.Bool, Required: false},
+		"packer_on_error":            &hcldec.AttrSpec{Name: "packer_on_error", Type: cty.String, Required: false},
+		"packer_user_variables":      &hcldec.AttrSpec{Name: "packer_user_variables", Type: cty.Map(cty.String), Required: false},
+		"packer_sensitive_variables": &hcldec.AttrSpec{Name: "packer_sensitive_variables", Type: cty.List(cty.String), Required: false},
+		"version":                    &hcldec.AttrSpec{Name: "version", Type: cty.String, Required: false},
+		"arch":                       &hcldec.AttrSpec{Name: "arch", Type: cty.String, Required: false},
+		"url":                        &hcldec.AttrSpec{Name: "url", Type: cty.String, Required: false},
+		"download_path":              &hcldec.AttrSpec{Name: "download_path", Type: cty.String, Required: false},
+		"username":                   &hcldec.AttrSpec{Name: "username", Type: cty.String, Required: false},
+		"password":                   &hcldec.AttrSpec{Name: "password", Type: cty.String, Required: false},
+		"skip_install":               &hcldec.AttrSpec{Name: "skip_install", Type: cty.Bool, Required: false},
+		"inspect":                    &hcldec.AttrSpec{Name: "inspect", Type: cty.Bool, Required: false},
+		"target_os":                  &hcldec.AttrSpec{Name: "target_os", Type: cty.String, Required: false},
+		"mode":                       &hcldec.AttrSpec{Name: "mode", Type: cty.String, Required: false},
+		"generate":                   &hcldec.AttrSpec{Name: "generate", Type: cty.Map(cty.List(cty.String)), Required: false},
+		"generate_dir":               &hcldec.AttrSpec{Name: "generate_dir", Type: cty.String, Required: false},
+		"tests":                      &hcldec.AttrSpec{Name: "tests", Type: cty.List(cty.String), Required: false},
+		"exclude":                    &hcldec.AttrSpec{Name: "exclude", Type: cty.List(cty.String), Required: false},
+		"retry_timeout":              &hcldec.AttrSpec{Name: "retry_timeout", Type: cty.String, Required: false},
+		"sleep":                      &hcldec.AttrSpec{Name: "sleep", Type: cty.String, Required: false},
+		"max_retry_attempts":         &hcldec.AttrSpec{Name: "max_retry_attempts", Type: cty.Number, Required: false},
+		"allow_execution_errors":     &hcldec.AttrSpec{Name: "allow_execution_errors", Type: cty.Bool, Required: false},
+		"severity_key":               &hcldec.AttrSpec{Name: "severity_key", Type: cty.String, Required: false},
+		"severity_policy":            &hcldec.AttrSpec{Name: "severity_policy", Type: cty.Map(cty.String), Required: false},
+		"compliance_report":          &hcldec.AttrSpec{Name: "compliance_report", Type: cty.Bool, Required: false},
+		"metrics_file":               &hcldec.AttrSpec{Name: "metrics_file", Type: cty.String, Required: false},
+		"attestation":                &hcldec.AttrSpec{Name: "attestation", Type: cty.Bool, Required: false},
+		"attestation_key":            &hcldec.AttrSpec{Name: "attestation_key", Type: cty.String, Required: false},
+		"use_sudo":                   &hcldec.AttrSpec{Name: "use_sudo", Type: cty.Bool, Required: false},
+		"elevated_user":              &hcldec.AttrSpec{Name: "elevated_user", Type: cty.String, Required: false},
+		"elevated_password":          &hcldec.AttrSpec{Name: "elevated_password", Type: cty.String, Required: false},
+		"shell":                      &hcldec.AttrSpec{Name: "shell", Type: cty.String, Required: false},
+		"skip_ssl":                   &hcldec.AttrSpec{Name: "skip_ssl", Type: cty.Bool, Required: false},
+		"http_proxy":                 &hcldec.AttrSpec{Name: "http_proxy", Type: cty.String, Required: false},
+		"https_proxy":                &hcldec.AttrSpec{Name: "https_proxy", Type: cty.String, Required: false},
+		"no_proxy":                   &hcldec.AttrSpec{Name: "no_proxy", Type: cty.String, Required: false},
+		"ca_bundle":                  &hcldec.AttrSpec{Name: "ca_bundle", Type: cty.String, Required: false},
+		"signature_public_key":       &hcldec.AttrSpec{Name: "signature_public_key", Type: cty.String, Required: false},
+		"signature_url":              &hcldec.AttrSpec{Name: "signature_url", Type: cty.String, Required: false},
+		"goss_file":                  &hcldec.AttrSpec{Name: "goss_file", Type: cty.String, Required: false},
+		"vars_file":                  &hcldec.AttrSpec{Name: "vars_file", Type: cty.String, Required: false},
+		"vars_inline":                &hcldec.AttrSpec{Name: "vars_inline", Type: cty.Map(cty.String), Required: false},
+		"vars_env":                   &hcldec.AttrSpec{Name: "vars_env", Type: cty.Map(cty.String), Required: false},
+		"remote_folder":              &hcldec.AttrSpec{Name: "remote_folder", Type: cty.String, Required: false},
+		"remote_path":                &hcldec.AttrSpec{Name: "remote_path", Type: cty.String, Required: false},
+		"preserve_paths":             &hcldec.AttrSpec{Name: "preserve_paths", Type: cty.Bool, Required: false},
+		"base_dir":                   &hcldec.AttrSpec{Name: "base_dir", Type: cty.String, Required: false},
+		"archive_upload":             &hcldec.AttrSpec{Name: "archive_upload", Type: cty.Bool, Required: false},
+		"skip_lint":                  &hcldec.AttrSpec{Name: "skip_lint", Type: cty.Bool, Required: false},
+		"skip_download":              &hcldec.AttrSpec{Name: "skip_download", Type: cty.Bool, Required: false},
+		"baseline_spec":              &hcldec.AttrSpec{Name: "baseline_spec", Type: cty.String, Required: false},
+		"on_drift":                   &hcldec.AttrSpec{Name: "on_drift", Type: cty.String, Required: false},
+		"on_failure_commands":        &hcldec.AttrSpec{Name: "on_failure_commands", Type: cty.List(cty.String), Required: false},
+		"on_failure_files":           &hcldec.AttrSpec{Name: "on_failure_files", Type: cty.List(cty.String), Required: false},
+		"dry_run":                    &hcldec.AttrSpec{Name: "dry_run", Type: cty.Bool, Required: false},
+		"dry_run_file":               &hcldec.AttrSpec{Name: "dry_run_file", Type: cty.String, Required: false},
+		"exec_folder":                &hcldec.AttrSpec{Name: "exec_folder", Type: cty.String, Required: false},
+		"format":                     &hcldec.AttrSpec{Name: "format", Type: cty.List(cty.String), Required: false},
+		"format_options":             &hcldec.AttrSpec{Name: "format_options", Type: cty.List(cty.String), Required: false},
+		"persist":                    &hcldec.BlockSpec{TypeName: "persist", Nested: hcldec.ObjectSpec((*FlatPersistConfig)(nil).HCL2Spec())},
+	}
+	return s
+}
+
+// FlatPersistConfig is an auto-generated flat version of PersistConfig.
+// Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
+type FlatPersistConfig struct {
+	BinaryPath *string `mapstructure:"binary_path" cty:"binary_path" hcl:"binary_path"`
+	SpecPath   *string `mapstructure:"spec_path" cty:"spec_path" hcl:"spec_path"`
+	Service    *bool   `mapstructure:"service" cty:"service" hcl:"service"`
+	Port       *int    `mapstructure:"port" cty:"port" hcl:"port"`
+}
+
+// FlatMapstructure returns a new FlatPersistConfig.
+// FlatPersistConfig is an auto-generated flat version of PersistConfig.
+// Where the contents a fields with a `mapstructure:,squash` tag are bubbled up.
+func (*PersistConfig) FlatMapstructure() interface{ HCL2Spec() map[string]hcldec.Spec } {
+	return new(FlatPersistConfig)
+}
+
+// HCL2Spec returns the hcl spec of a PersistConfig.
+// This spec is used by HCL to read the fields of PersistConfig.
+// The decoded values from this spec will then be applied to a FlatPersistConfig.
+func (*FlatPersistConfig) HCL2Spec() map[string]hcldec.Spec {
+	s := map[string]hcldec.Spec{
+		"binary_path": &hcldec.AttrSpec{Name: "binary_path", Type: cty.String, Required: false},
+		"spec_path":   &hcldec.AttrSpec{Name: "spec_path", Type: cty.String, Required: false},
+		"service":     &hcldec.AttrSpec{Name: "service", Type: cty.Bool, Required: false},
+		"port":        &hcldec.AttrSpec{Name: "port", Type: cty.Number, Required: false},
 	}
 	return s
 }
@@ -4,8 +4,10 @@ package goss
 
 import (
 	"reflect"
+	"strings"
 	"testing"
 
+	"github.com/hashicorp/packer-plugin-sdk/packer"
 	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
 )
 
@@ -27,12 +29,15 @@ func fakeContext() interpolate.Context {
 }
 
 func TestProvisioner_Prepare(t *testing.T) {
+	attestationKey, _ := writeAttestationKey(t)
+	signatureKey, _ := testKeyPair(t, "12345678")
 
 	var tests = []struct {
-		name       string
-		input      []interface{}
-		wantErr    bool
-		wantConfig GossConfig
+		name    string
+		input   []interface{}
+		wantErr bool
+		// Compared with the config when set, other cases only check the error
+		wantConfig *GossConfig
 	}{
 		{
 			name: "defaults",
@@ -42,7 +47,7 @@ func TestProvisioner_Prepare(t *testing.T) {
 				},
 			},
 			wantErr: false,
-			wantConfig: GossConfig{
+			wantConfig: &GossConfig{
 				Version:       "0.4.2",
 				Arch:          "amd64",
 				URL:           "https://github.com/goss-org/goss/releases/download/v0.4.2/goss-linux-amd64",
@@ -63,8 +68,8 @@ func TestProvisioner_Prepare(t *testing.T) {
 				VarsEnv:       nil,
 				RemoteFolder:  "/tmp",
 				RemotePath:    "/tmp/goss",
-				Format:        "",
-				FormatOptions: "",
+				Format:        nil,
+				FormatOptions: nil,
 				ctx:           fakeContext(),
 			},
 		},
@@ -80,11 +85,11 @@ func TestProvisioner_Prepare(t *testing.T) {
 				},
 			},
 			wantErr: false,
-			wantConfig: GossConfig{
+			wantConfig: &GossConfig{
 				Version:      "0.4.2",
 				Arch:         "amd64",
 				URL:          "https://github.com/goss-org/goss/releases/download/v0.4.2/goss-windows-amd64.exe",
-				DownloadPath: "/tmp/goss-0.4.2-windows-amd64.exe",
+				DownloadPath: "C:/Windows/Temp/goss-0.4.2-windows-amd64.exe",
 				Username:     "",
 				Password:     "",
 				SkipInstall:  false,
@@ -101,10 +106,10 @@ func TestProvisioner_Prepare(t *testing.T) {
 				VarsEnv: map[string]string{
 					"GOSS_USE_ALPHA": "1",
 				},
-				RemoteFolder:  "/tmp",
-				RemotePath:    "/tmp/goss",
-				Format:        "",
-				FormatOptions: "",
+				RemoteFolder:  "C:/Windows/Temp",
+				RemotePath:    "C:/Windows/Temp/goss",
+				Format:        nil,
+				FormatOptions: nil,
 				ctx:           fakeContext(),
 			},
 		},
@@ -117,11 +122,11 @@ func TestProvisioner_Prepare(t *testing.T) {
 				},
 			},
 			wantErr: false,
-			wantConfig: GossConfig{
+			wantConfig: &GossConfig{
 				Version:       "0.4.2",
 				Arch:          "amd64",
 				URL:           "https://github.com/goss-org/goss/releases/download/v0.4.2/goss-windows-amd64.exe",
-				DownloadPath:  "/tmp/goss-0.4.2-windows-amd64.exe",
+				DownloadPath:  "C:/Windows/Temp/goss-0.4.2-windows-amd64.exe",
 				Username:      "",
 				Password:      "",
 				SkipInstall:   false,
@@ -136,13 +141,737 @@ func TestProvisioner_Prepare(t *testing.T) {
 				VarsFile:      "",
 				VarsInline:    nil,
 				VarsEnv:       nil,
-				RemoteFolder:  "/tmp",
-				RemotePath:    "/tmp/goss",
-				Format:        "",
-				FormatOptions: "",
+				RemoteFolder:  "C:/Windows/Temp",
+				RemotePath:    "C:/Windows/Temp/goss",
+				Format:        nil,
+				FormatOptions: nil,
 				ctx:           fakeContext(),
 			},
 		},
+		{
+			name: "Darwin",
+			input: []interface{}{
+				map[string]interface{}{
+					"tests":     []string{"../../example/goss"},
+					"target_os": "Darwin",
+					"arch":      "arm64",
+				},
+			},
+			wantErr: false,
+			wantConfig: &GossConfig{
+				Version:      "0.4.2",
+				Arch:         "arm64",
+				URL:          "https://github.com/goss-org/goss/releases/download/v0.4.2/goss-darwin-arm64",
+				DownloadPath: "/tmp/goss-0.4.2-darwin-arm64",
+				TargetOs:     "Darwin",
+				Tests:        []string{"../../example/goss"},
+				RemoteFolder: "/tmp",
+				RemotePath:   "/tmp/goss",
+				ctx:          fakeContext(),
+			},
+		},
+		{
+			name: "Darwin alpha",
+			input: []interface{}{
+				map[string]interface{}{
+					"tests":     []string{"../../example/goss"},
+					"target_os": "Darwin",
+					"version":   "0.3.23",
+					"vars_env": map[string]string{
+						"GOSS_USE_ALPHA": "1",
+					},
+				},
+			},
+			wantErr: false,
+			wantConfig: &GossConfig{
+				Version:      "0.3.23",
+				Arch:         "amd64",
+				URL:          "https://github.com/goss-org/goss/releases/download/v0.3.23/goss-alpha-darwin-amd64",
+				DownloadPath: "/tmp/goss-0.3.23-darwin-amd64",
+				TargetOs:     "Darwin",
+				Tests:        []string{"../../example/goss"},
+				VarsEnv: map[string]string{
+					"GOSS_USE_ALPHA": "1",
+				},
+				RemoteFolder: "/tmp",
+				RemotePath:   "/tmp/goss",
+				ctx:          fakeContext(),
+			},
+		},
+		{
+			name: "Darwin without alpha",
+			input: []interface{}{
+				map[string]interface{}{
+					"tests":     []string{"../../example/goss"},
+					"target_os": "Darwin",
+					"version":   "0.3.23",
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "unknown os",
+			input: []interface{}{
+				map[string]interface{}{
+					"tests":     []string{"../../example/goss"},
+					"target_os": "Plan9",
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid durations",
+			input: []interface{}{
+				map[string]interface{}{
+					"tests":              []string{"../../example/goss"},
+					"retry_timeout":      "5m",
+					"sleep":              "10s",
+					"max_retry_attempts": 3,
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid retry timeout",
+			input: []interface{}{
+				map[string]interface{}{
+					"tests":         []string{"../../example/goss"},
+					"retry_timeout": "5 min",
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid sleep",
+			input: []interface{}{
+				map[string]interface{}{
+					"tests": []string{"../../example/goss"},
+					"sleep": "1",
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative retry attempts",
+			input: []interface{}{
+				map[string]interface{}{
+					"tests":              []string{"../../example/goss"},
+					"max_retry_attempts": -1,
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "proxies",
+			input: []interface{}{
+				map[string]interface{}{
+					"tests":       []string{"../../example/goss"},
+					"http_proxy":  "http://proxy:3128",
+					"https_proxy": "http://proxy:3128",
+					"no_proxy":    "localhost,.example.com",
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid proxy",
+			input: []interface{}{
+				map[string]interface{}{
+					"tests":       []string{"../../example/goss"},
+					"https_proxy": "proxy:3128",
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "missing ca bundle",
+			input: []interface{}{
+				map[string]interface{}{
+					"tests":     []string{"../../example/goss"},
+					"ca_bundle": "does-not-exist.pem",
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "templated url",
+			input: []interface{}{
+				map[string]interface{}{
+					"tests": []string{"../../example/goss"},
+					"url":   "https://mirror.example.com/goss/v{{.Version}}/goss-{{.OS}}-{{.Arch}}{{.Ext}}",
+				},
+			},
+			wantErr: false,
+			wantConfig: &GossConfig{
+				Version:      "0.4.2",
+				Arch:         "amd64",
+				URL:          "https://mirror.example.com/goss/v0.4.2/goss-linux-amd64",
+				DownloadPath: "/tmp/goss-0.4.2-linux-amd64",
+				TargetOs:     "Linux",
+				Tests:        []string{"../../example/goss"},
+				RemoteFolder: "/tmp",
+				RemotePath:   "/tmp/goss",
+				ctx:          fakeContext(),
+			},
+		},
+		{
+			name: "templated url on Windows",
+			input: []interface{}{
+				map[string]interface{}{
+					"tests":     []string{"../../example/goss"},
+					"url":       "https://mirror.example.com/goss/v{{.Version}}/goss-{{.OS}}-{{.Arch}}{{.Ext}}",
+					"target_os": "Windows",
+					"version":   "0.3.23",
+				},
+			},
+			wantErr: false,
+			wantConfig: &GossConfig{
+				Version:      "0.3.23",
+				Arch:         "amd64",
+				URL:          "https://mirror.example.com/goss/v0.3.23/goss-windows-amd64.exe",
+				DownloadPath: "C:/Windows/Temp/goss-0.3.23-windows-amd64.exe",
+				TargetOs:     "Windows",
+				Tests:        []string{"../../example/goss"},
+				RemoteFolder: "C:/Windows/Temp",
+				RemotePath:   "C:/Windows/Temp/goss",
+				ctx:          fakeContext(),
+			},
+		},
+		{
+			name: "flat mirror url",
+			input: []interface{}{
+				map[string]interface{}{
+					"tests": []string{"../../example/goss"},
+					"url":   "https://mirror.example.com/tools/goss",
+					"arch":  "arm64",
+				},
+			},
+			wantErr: false,
+			wantConfig: &GossConfig{
+				Version:      "0.4.2",
+				Arch:         "arm64",
+				URL:          "https://mirror.example.com/tools/goss",
+				DownloadPath: "/tmp/goss-0.4.2-linux-arm64",
+				TargetOs:     "Linux",
+				Tests:        []string{"../../example/goss"},
+				RemoteFolder: "/tmp",
+				RemotePath:   "/tmp/goss",
+				ctx:          fakeContext(),
+			},
+		},
+		{
+			name: "invalid url template",
+			input: []interface{}{
+				map[string]interface{}{
+					"tests": []string{"../../example/goss"},
+					"url":   "https://mirror.example.com/goss-{{.OS",
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "relative url",
+			input: []interface{}{
+				map[string]interface{}{
+					"tests": []string{"../../example/goss"},
+					"url":   "mirror.example.com/goss-{{.OS}}-{{.Arch}}",
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "ca bundle with skip ssl",
+			input: []interface{}{
+				map[string]interface{}{
+					"tests":     []string{"../../example/goss"},
+					"ca_bundle": "../../example/goss/goss.yaml",
+					"skip_ssl":  true,
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "format string",
+			input: []interface{}{
+				map[string]interface{}{
+					"tests":  []string{"../../example/goss"},
+					"format": "junit",
+				},
+			},
+			wantErr: false,
+			wantConfig: &GossConfig{
+				Version:      "0.4.2",
+				Arch:         "amd64",
+				URL:          "https://github.com/goss-org/goss/releases/download/v0.4.2/goss-linux-amd64",
+				DownloadPath: "/tmp/goss-0.4.2-linux-amd64",
+				TargetOs:     "Linux",
+				Tests:        []string{"../../example/goss"},
+				Format:       []string{"junit"},
+				RemoteFolder: "/tmp",
+				RemotePath:   "/tmp/goss",
+				ctx:          fakeContext(),
+			},
+		},
+		{
+			name: "format list",
+			input: []interface{}{
+				map[string]interface{}{
+					"tests":  []string{"../../example/goss"},
+					"format": []string{"rspecish", "junit", "json"},
+				},
+			},
+			wantErr: false,
+			wantConfig: &GossConfig{
+				Version:      "0.4.2",
+				Arch:         "amd64",
+				URL:          "https://github.com/goss-org/goss/releases/download/v0.4.2/goss-linux-amd64",
+				DownloadPath: "/tmp/goss-0.4.2-linux-amd64",
+				TargetOs:     "Linux",
+				Tests:        []string{"../../example/goss"},
+				Format:       []string{"rspecish", "junit", "json"},
+				RemoteFolder: "/tmp",
+				RemotePath:   "/tmp/goss",
+				ctx:          fakeContext(),
+			},
+		},
+		{
+			name: "invalid format",
+			input: []interface{}{
+				map[string]interface{}{
+					"tests":  []string{"../../example/goss"},
+					"format": []string{"rspecish", "xml"},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "duplicate format",
+			input: []interface{}{
+				map[string]interface{}{
+					"tests":  []string{"../../example/goss"},
+					"format": []string{"junit", "junit"},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "silent saved format",
+			input: []interface{}{
+				map[string]interface{}{
+					"tests":  []string{"../../example/goss"},
+					"format": []string{"rspecish", "silent"},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "json first for severity policy",
+			input: []interface{}{
+				map[string]interface{}{
+					"tests":           []string{"../../example/goss"},
+					"format":          []string{"json", "junit"},
+					"severity_policy": map[string]string{"critical": "fail"},
+				},
+			},
+			wantErr: false,
+			wantConfig: &GossConfig{
+				Version:        "0.4.2",
+				Arch:           "amd64",
+				URL:            "https://github.com/goss-org/goss/releases/download/v0.4.2/goss-linux-amd64",
+				DownloadPath:   "/tmp/goss-0.4.2-linux-amd64",
+				TargetOs:       "Linux",
+				Tests:          []string{"../../example/goss"},
+				Format:         []string{"json", "junit"},
+				SeverityPolicy: map[string]string{"critical": "fail"},
+				RemoteFolder:   "/tmp",
+				RemotePath:     "/tmp/goss",
+				ctx:            fakeContext(),
+			},
+		},
+		{
+			name: "json not first for severity policy",
+			input: []interface{}{
+				map[string]interface{}{
+					"tests":           []string{"../../example/goss"},
+					"format":          []string{"junit", "json"},
+					"severity_policy": map[string]string{"critical": "fail"},
+				},
+			},
+			wantErr: false,
+			wantConfig: &GossConfig{
+				Version:        "0.4.2",
+				Arch:           "amd64",
+				URL:            "https://github.com/goss-org/goss/releases/download/v0.4.2/goss-linux-amd64",
+				DownloadPath:   "/tmp/goss-0.4.2-linux-amd64",
+				TargetOs:       "Linux",
+				Tests:          []string{"../../example/goss"},
+				Format:         []string{"junit", "json"},
+				SeverityPolicy: map[string]string{"critical": "fail"},
+				RemoteFolder:   "/tmp",
+				RemotePath:     "/tmp/goss",
+				ctx:            fakeContext(),
+			},
+		},
+		{
+			name: "format option string",
+			input: []interface{}{
+				map[string]interface{}{
+					"tests":          []string{"../../example/goss"},
+					"format":         "nagios",
+					"format_options": "perfdata",
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "format option list",
+			input: []interface{}{
+				map[string]interface{}{
+					"tests":          []string{"../../example/goss"},
+					"format":         "nagios",
+					"format_options": []string{"perfdata", "verbose"},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid format option",
+			input: []interface{}{
+				map[string]interface{}{
+					"tests":          []string{"../../example/goss"},
+					"format":         "nagios",
+					"format_options": []string{"perfdata", "shiny"},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "pretty with tap",
+			input: []interface{}{
+				map[string]interface{}{
+					"tests":          []string{"../../example/goss"},
+					"format":         "tap",
+					"format_options": []string{"pretty"},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "pretty with a saved json format",
+			input: []interface{}{
+				map[string]interface{}{
+					"tests":          []string{"../../example/goss"},
+					"format":         []string{"tap", "json"},
+					"format_options": []string{"pretty"},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "valid severity policy",
+			input: []interface{}{
+				map[string]interface{}{
+					"tests":           []string{"../../example/goss"},
+					"severity_policy": map[string]string{"critical": "fail", "warn": "warn", "info": "ignore"},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid severity action",
+			input: []interface{}{
+				map[string]interface{}{
+					"tests":           []string{"../../example/goss"},
+					"severity_policy": map[string]string{"critical": "explode"},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "severity policy with junit",
+			input: []interface{}{
+				map[string]interface{}{
+					"tests":           []string{"../../example/goss"},
+					"severity_policy": map[string]string{"critical": "fail"},
+					"format":          "junit",
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "baseline spec",
+			input: []interface{}{
+				map[string]interface{}{
+					"tests":         []string{"../../example/goss"},
+					"baseline_spec": "../../example/goss/goss.yaml",
+					"on_drift":      "warn",
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "missing baseline spec",
+			input: []interface{}{
+				map[string]interface{}{
+					"tests":         []string{"../../example/goss"},
+					"baseline_spec": "baseline.yaml",
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid on_drift",
+			input: []interface{}{
+				map[string]interface{}{
+					"tests":    []string{"../../example/goss"},
+					"on_drift": "ignore",
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "elevated on Windows",
+			input: []interface{}{
+				map[string]interface{}{
+					"tests":             []string{"../../example/goss"},
+					"target_os":         "Windows",
+					"elevated_user":     "Administrator",
+					"elevated_password": "secret",
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "elevated on Linux",
+			input: []interface{}{
+				map[string]interface{}{
+					"tests":         []string{"../../example/goss"},
+					"elevated_user": "root",
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "elevated password without user",
+			input: []interface{}{
+				map[string]interface{}{
+					"tests":             []string{"../../example/goss"},
+					"target_os":         "Windows",
+					"elevated_password": "secret",
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "persist",
+			input: []interface{}{
+				map[string]interface{}{
+					"tests":   []string{"../../example/goss"},
+					"persist": map[string]interface{}{"service": true, "port": 9100},
+				},
+			},
+			wantErr: false,
+			wantConfig: &GossConfig{
+				Version:      "0.4.2",
+				Arch:         "amd64",
+				URL:          "https://github.com/goss-org/goss/releases/download/v0.4.2/goss-linux-amd64",
+				DownloadPath: "/tmp/goss-0.4.2-linux-amd64",
+				TargetOs:     "Linux",
+				Tests:        []string{"../../example/goss"},
+				Persist:      &PersistConfig{Service: true, Port: 9100},
+				RemoteFolder: "/tmp",
+				RemotePath:   "/tmp/goss",
+				ctx:          fakeContext(),
+			},
+		},
+		{
+			name: "invalid persist port",
+			input: []interface{}{
+				map[string]interface{}{
+					"tests":   []string{"../../example/goss"},
+					"persist": map[string]interface{}{"port": 70000},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "persist service on macOS",
+			input: []interface{}{
+				map[string]interface{}{
+					"tests":     []string{"../../example/goss"},
+					"target_os": "Darwin",
+					"persist":   map[string]interface{}{"service": true},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "attestation",
+			input: []interface{}{
+				map[string]interface{}{
+					"tests":           []string{"../../example/goss"},
+					"attestation":     true,
+					"attestation_key": attestationKey,
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "attestation without key",
+			input: []interface{}{
+				map[string]interface{}{
+					"tests":       []string{"../../example/goss"},
+					"attestation": true,
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "attestation key not a key",
+			input: []interface{}{
+				map[string]interface{}{
+					"tests":           []string{"../../example/goss"},
+					"attestation":     true,
+					"attestation_key": "../../example/goss/goss.yaml",
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "attestation with rspecish",
+			input: []interface{}{
+				map[string]interface{}{
+					"tests":           []string{"../../example/goss"},
+					"attestation":     true,
+					"attestation_key": attestationKey,
+					"format":          "rspecish",
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "signature public key",
+			input: []interface{}{
+				map[string]interface{}{
+					"tests":                []string{"../../example/goss"},
+					"signature_public_key": signatureKey,
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid signature public key",
+			input: []interface{}{
+				map[string]interface{}{
+					"tests":                []string{"../../example/goss"},
+					"signature_public_key": "RWQ-not-a-key",
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "signature url without public key",
+			input: []interface{}{
+				map[string]interface{}{
+					"tests":         []string{"../../example/goss"},
+					"signature_url": "https://mirror.example.com/goss.minisig",
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "generate without tests",
+			input: []interface{}{
+				map[string]interface{}{
+					"mode":     "generate",
+					"generate": map[string][]string{"package": {"nginx"}, "autoadd": {"nginx"}},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "nothing to generate",
+			input: []interface{}{
+				map[string]interface{}{
+					"mode": "generate",
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "unknown generate resource type",
+			input: []interface{}{
+				map[string]interface{}{
+					"mode":     "generate",
+					"generate": map[string][]string{"matching": {"nginx"}},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "generate with tests",
+			input: []interface{}{
+				map[string]interface{}{
+					"mode":     "generate",
+					"generate": map[string][]string{"service": {"nginx"}},
+					"tests":    []string{"../../example/goss"},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "generate with persist",
+			input: []interface{}{
+				map[string]interface{}{
+					"mode":     "generate",
+					"generate": map[string][]string{"service": {"nginx"}},
+					"persist":  map[string]interface{}{"service": true},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "generate with baseline spec",
+			input: []interface{}{
+				map[string]interface{}{
+					"mode":          "generate",
+					"generate":      map[string][]string{"service": {"nginx"}},
+					"baseline_spec": "../../example/goss/goss.yaml",
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "generate with attestation",
+			input: []interface{}{
+				map[string]interface{}{
+					"mode":            "generate",
+					"generate":        map[string][]string{"service": {"nginx"}},
+					"attestation":     true,
+					"attestation_key": attestationKey,
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "unknown mode",
+			input: []interface{}{
+				map[string]interface{}{
+					"mode":  "bootstrap",
+					"tests": []string{"../../example/goss"},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "validate without tests",
+			input: []interface{}{
+				map[string]interface{}{
+					"mode": "validate",
+				},
+			},
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -156,7 +885,7 @@ func TestProvisioner_Prepare(t *testing.T) {
 				t.Errorf("Provisioner.Prepare() error = %v, wantErr %v", err, tt.wantErr)
 			}
 
-			if err == nil && !reflect.DeepEqual(p.config, tt.wantConfig) {
+			if err == nil && tt.wantConfig != nil && !reflect.DeepEqual(p.config, *tt.wantConfig) {
 				t.Error("configs do not match")
 				t.Logf("got config= %v", p.config)
 				t.Logf("want config= %v", tt.wantConfig)
@@ -193,6 +922,16 @@ func TestProvisioner_envVars(t *testing.T) {
 			},
 			want: "set \"GOSS_USE_ALPHA=1\" && ",
 		},
+		{
+			name: "Darwin",
+			config: GossConfig{
+				TargetOs: "Darwin",
+				VarsEnv: map[string]string{
+					"somevar": "1",
+				},
+			},
+			want: "somevar=\"1\" ",
+		},
 		{
 			name: "no vars windows",
 			config: GossConfig{
@@ -254,6 +993,14 @@ func TestProvisioner_mkDir(t *testing.T) {
 			dir:     "/tmp",
 			wantcmd: "powershell /c mkdir -p '/tmp'",
 		},
+		{
+			name: "darwin",
+			config: GossConfig{
+				TargetOs: darwin,
+			},
+			dir:     "/tmp",
+			wantcmd: "mkdir -p '/tmp'",
+		},
 		{
 			name:    "no configured os",
 			config:  GossConfig{},
@@ -317,3 +1064,113 @@ func TestProvisioner_lessThan(t *testing.T) {
 		})
 	}
 }
+
+func TestProvisioner_retryBudget(t *testing.T) {
+	tests := []struct {
+		name   string
+		config GossConfig
+		want   string
+	}{
+		{
+			name:   "defaults",
+			config: GossConfig{},
+			want:   "Goss retry budget: 1 attempt(s), each retrying for up to 0s with 1s sleep (0s in total)",
+		},
+		{
+			name: "attempts",
+			config: GossConfig{
+				RetryTimeout:     "2m",
+				Sleep:            "5s",
+				MaxRetryAttempts: 3,
+			},
+			want: "Goss retry budget: 3 attempt(s), each retrying for up to 2m with 5s sleep (6m0s in total)",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &Provisioner{
+				config: tt.config,
+			}
+			if got := p.retryBudget(); got != tt.want {
+				t.Errorf("Provisioner.retryBudget() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProvisioner_downloadCmd(t *testing.T) {
+	tests := []struct {
+		name   string
+		config GossConfig
+		want   string
+	}{
+		{
+			name: "defaults",
+			config: GossConfig{
+				TargetOs:     linux,
+				URL:          "https://example.com/goss",
+				DownloadPath: "/tmp/goss",
+			},
+			want: "curl -sL    -o /tmp/goss https://example.com/goss || wget -q    -O /tmp/goss https://example.com/goss",
+		},
+		{
+			name: "proxy and ca bundle",
+			config: GossConfig{
+				TargetOs:     linux,
+				URL:          "https://example.com/goss",
+				DownloadPath: "/tmp/goss",
+				RemotePath:   "/tmp/goss-tests",
+				HTTPSProxy:   "http://proxy:3128",
+				NoProxy:      "localhost",
+				CABundle:     "ca.pem",
+			},
+			want: "https_proxy=\"http://proxy:3128\" no_proxy=\"localhost\" curl -sL  --cacert /tmp/goss-tests/goss-ca-bundle.pem  -o /tmp/goss https://example.com/goss || " +
+				"https_proxy=\"http://proxy:3128\" no_proxy=\"localhost\" wget -q  --ca-certificate=/tmp/goss-tests/goss-ca-bundle.pem  -O /tmp/goss https://example.com/goss",
+		},
+		{
+			name: "windows cmd proxy",
+			config: GossConfig{
+				TargetOs:     windows,
+				URL:          "https://example.com/goss.exe",
+				DownloadPath: "C:/goss.exe",
+				HTTPProxy:    "http://proxy:3128",
+			},
+			want: "set \"http_proxy=http://proxy:3128\" && curl -sL    -o C:/goss.exe https://example.com/goss.exe || wget -q    -O C:/goss.exe https://example.com/goss.exe",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &Provisioner{
+				config: tt.config,
+			}
+			if got := p.downloadCmd(); got != tt.want {
+				t.Errorf("Provisioner.downloadCmd() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProvisioner_downloadGoss(t *testing.T) {
+	p := &Provisioner{
+		config: GossConfig{
+			TargetOs:     linux,
+			URL:          "https://example.com/goss",
+			DownloadPath: "/tmp/goss-bin",
+			RemotePath:   "/tmp/goss",
+			CABundle:     "../../example/goss/goss.yaml",
+		},
+	}
+	plan := p.newDryRunPlan(packer.TestUi(t))
+	if err := p.downloadGoss(packer.TestUi(t), plan); err != nil {
+		t.Fatalf("Provisioner.downloadGoss() error = %v", err)
+	}
+
+	var got []string
+	for _, step := range plan.Steps {
+		got = append(got, step.Action+" "+step.Destination+step.Command)
+	}
+	if len(got) != 3 || !strings.HasPrefix(got[0], "upload /tmp/goss/goss-ca-bundle.pem") ||
+		!strings.Contains(got[1], "curl") || got[2] != "run rm -f '/tmp/goss/goss-ca-bundle.pem'" {
+		t.Errorf("steps = %q, want the CA bundle uploaded, goss downloaded and the CA bundle removed", got)
+	}
+}
@@ -0,0 +1,189 @@
+package goss
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+const (
+	defaultPersistBinary        = "/usr/local/bin/goss"
+	defaultPersistSpec          = "/etc/goss/goss.yaml"
+	defaultPersistBinaryWindows = "C:/Program Files/goss/goss.exe"
+	defaultPersistSpecWindows   = "C:/ProgramData/goss/goss.yaml"
+	defaultPersistPort          = 8080
+
+	// persistService names the systemd unit and the scheduled task running goss serve
+	persistService = "goss-serve"
+	// gossUseAlpha is passed on to goss serve, goss requires it on Windows and macOS
+	gossUseAlpha = "GOSS_USE_ALPHA"
+)
+
+func (p *Provisioner) persistBinaryPath() string {
+	if p.config.Persist.BinaryPath != "" {
+		return p.config.Persist.BinaryPath
+	}
+	if p.config.TargetOs == windows {
+		return defaultPersistBinaryWindows
+	}
+	return defaultPersistBinary
+}
+
+func (p *Provisioner) persistSpecPath() string {
+	if p.config.Persist.SpecPath != "" {
+		return p.config.Persist.SpecPath
+	}
+	if p.config.TargetOs == windows {
+		return defaultPersistSpecWindows
+	}
+	return defaultPersistSpec
+}
+
+func (p *Provisioner) persistPort() int {
+	if p.config.Persist.Port == 0 {
+		return defaultPersistPort
+	}
+	return p.config.Persist.Port
+}
+
+// checkPersist validates the persist block
+func (p *Provisioner) checkPersist() []error {
+	var errs []error
+	if port := p.config.Persist.Port; port < 0 || port > 65535 {
+		errs = append(errs, fmt.Errorf("Invalid persist port %d", port))
+	}
+	if p.config.Persist.Service && p.config.TargetOs == darwin {
+		errs = append(errs, fmt.Errorf("persist service isn't supported on %s, only goss and the spec are persisted", darwin))
+	}
+	return errs
+}
+
+// serveArgs are the arguments of goss serve for the persisted spec
+func (p *Provisioner) serveArgs(quote func(string) string) string {
+	return fmt.Sprintf("--gossfile %s serve --listen-addr :%d", quote(p.persistSpecPath()), p.persistPort())
+}
+
+// systemdQuote quotes s as a single argument of a systemd unit setting, escaping
+// the specifiers and variables systemd would expand
+func systemdQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "%", "%%", "$", "$$").Replace(s) + `"`
+}
+
+// systemdUnit returns the systemd unit running goss serve
+func (p *Provisioner) systemdUnit() []string {
+	unit := []string{
+		"[Unit]",
+		"Description=goss health endpoint",
+		"Wants=network-online.target",
+		"After=network-online.target",
+		"",
+		"[Service]",
+	}
+	if alpha := p.config.VarsEnv[gossUseAlpha]; alpha != "" {
+		unit = append(unit, fmt.Sprintf("Environment=%s", systemdQuote(gossUseAlpha+"="+alpha)))
+	}
+	return append(unit,
+		fmt.Sprintf("ExecStart=%s %s", systemdQuote(p.persistBinaryPath()), p.serveArgs(systemdQuote)),
+		"Restart=on-failure",
+		"",
+		"[Install]",
+		"WantedBy=multi-user.target",
+	)
+}
+
+// persistCmdPosix returns the command installing goss, the spec and the unit on Linux and macOS
+func (p *Provisioner) persistCmdPosix() string {
+	binary, spec := p.persistBinaryPath(), p.persistSpecPath()
+	cmds := []string{
+		fmt.Sprintf("mkdir -p %s %s", shQuote(path.Dir(binary)), shQuote(path.Dir(spec))),
+		fmt.Sprintf("cp %s %s", shQuote(p.config.DownloadPath), shQuote(binary)),
+		fmt.Sprintf("chmod 755 %s", shQuote(binary)),
+		fmt.Sprintf("cp %s %s", shQuote(p.specFile()), shQuote(spec)),
+		fmt.Sprintf("chmod 644 %s", shQuote(spec)),
+	}
+	if p.config.Persist.Service {
+		unit := fmt.Sprintf("/etc/systemd/system/%s.service", persistService)
+		lines := make([]string, 0, len(p.systemdUnit()))
+		for _, line := range p.systemdUnit() {
+			lines = append(lines, shQuote(line))
+		}
+		cmds = append(cmds,
+			fmt.Sprintf("printf '%%s\\n' %s > %s", strings.Join(lines, " "), unit),
+			"systemctl daemon-reload",
+			fmt.Sprintf("systemctl enable %s.service", persistService),
+		)
+	}
+
+	cmd := strings.Join(cmds, " && ")
+	if p.config.UseSudo {
+		return fmt.Sprintf("sudo sh -c %s", shQuote(cmd))
+	}
+	// Only root can write to the system paths, other users go through sudo
+	return fmt.Sprintf(`$(test "$(id -u)" -eq 0 || echo sudo) sh -c %s`, shQuote(cmd))
+}
+
+// persistScriptWindows returns the PowerShell script installing goss, the spec and the scheduled task
+func (p *Provisioner) persistScriptWindows() string {
+	binary, spec := p.persistBinaryPath(), p.persistSpecPath()
+	lines := []string{
+		"$ErrorActionPreference = 'Stop'",
+		fmt.Sprintf("New-Item -ItemType Directory -Force -Path %s, %s | Out-Null",
+			psQuote(path.Dir(binary)), psQuote(path.Dir(spec))),
+		fmt.Sprintf("Copy-Item -Force -Path %s -Destination %s", psQuote(p.config.DownloadPath), psQuote(binary)),
+		fmt.Sprintf("Copy-Item -Force -Path %s -Destination %s", psQuote(p.specFile()), psQuote(spec)),
+	}
+	if p.config.Persist.Service {
+		if alpha := p.config.VarsEnv[gossUseAlpha]; alpha != "" {
+			lines = append(lines, fmt.Sprintf("[Environment]::SetEnvironmentVariable(%s, %s, 'Machine')",
+				psQuote(gossUseAlpha), psQuote(alpha)))
+		}
+		quote := func(s string) string { return `"` + s + `"` }
+		lines = append(lines,
+			fmt.Sprintf("$action = New-ScheduledTaskAction -Execute %s -Argument %s",
+				psQuote(binary), psQuote(p.serveArgs(quote))),
+			"$trigger = New-ScheduledTaskTrigger -AtStartup",
+			"$settings = New-ScheduledTaskSettingsSet -ExecutionTimeLimit ([TimeSpan]::Zero) -RestartCount 3 -RestartInterval (New-TimeSpan -Minutes 1)",
+			fmt.Sprintf("Register-ScheduledTask -Force -TaskName %s -Action $action -Trigger $trigger -Settings $settings -User 'SYSTEM' -RunLevel Highest | Out-Null",
+				psQuote(persistService)),
+		)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// persistCmd returns the command persisting goss and the rendered spec for the target OS
+func (p *Provisioner) persistCmd() string {
+	if p.config.TargetOs == windows {
+		return encodePowerShell(p.persistScriptWindows())
+	}
+	return p.persistCmdPosix()
+}
+
+// persist installs goss and the rendered spec to their permanent paths
+// and optionally a service running goss serve
+func (p *Provisioner) persist(ui packer.Ui, comm packer.Communicator) error {
+	ui.Message(fmt.Sprintf("Installing Goss to %s and the rendered spec to %s",
+		p.persistBinaryPath(), p.persistSpecPath()))
+	if p.config.Persist.Service {
+		ui.Message(fmt.Sprintf("Installing %s running goss serve on port %d", persistService, p.persistPort()))
+	}
+
+	command := p.persistCmd()
+	if p.elevated() {
+		var err error
+		if command, err = p.elevatedCmd(command); err != nil {
+			return err
+		}
+	}
+
+	cmd := &packer.RemoteCmd{Command: command}
+	if err := cmd.RunWithUi(context.TODO(), comm, ui); err != nil {
+		return err
+	}
+	if cmd.ExitStatus() != 0 {
+		return fmt.Errorf("non-zero exit status %d", cmd.ExitStatus())
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package goss
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+func TestProvisioner_persistCmd(t *testing.T) {
+	tests := []struct {
+		name   string
+		config GossConfig
+		want   []string
+		reject []string
+	}{
+		{
+			name: "linux",
+			config: GossConfig{
+				TargetOs:     linux,
+				RemoteFolder: "/tmp",
+				DownloadPath: "/tmp/goss-0.4.2-linux-amd64",
+				Persist:      &PersistConfig{},
+			},
+			want: []string{
+				`$(test "$(id -u)" -eq 0 || echo sudo) sh -c 'mkdir -p '\''/usr/local/bin'\'' '\''/etc/goss'\''`,
+				`cp '\''/tmp/goss-0.4.2-linux-amd64'\'' '\''/usr/local/bin/goss'\''`,
+				`cp '\''/tmp/goss-spec.yaml'\'' '\''/etc/goss/goss.yaml'\''`,
+			},
+			reject: []string{"systemctl"},
+		},
+		{
+			name: "linux service",
+			config: GossConfig{
+				TargetOs:     linux,
+				RemoteFolder: "/tmp",
+				DownloadPath: "/tmp/goss-0.4.2-linux-amd64",
+				UseSudo:      true,
+				Persist:      &PersistConfig{Service: true, Port: 9100, SpecPath: "/opt/goss specs/100%.yaml"},
+			},
+			want: []string{
+				"sudo sh -c ",
+				`ExecStart="/usr/local/bin/goss" --gossfile "/opt/goss specs/100%%.yaml" serve --listen-addr :9100`,
+				"/etc/systemd/system/goss-serve.service",
+				"systemctl enable goss-serve.service",
+			},
+		},
+		{
+			name: "windows service",
+			config: GossConfig{
+				TargetOs:     windows,
+				RemoteFolder: "C:/Windows/Temp",
+				DownloadPath: "C:/Windows/Temp/goss-0.4.2-windows-amd64.exe",
+				VarsEnv:      map[string]string{"GOSS_USE_ALPHA": "1"},
+				Persist:      &PersistConfig{Service: true},
+			},
+			want: []string{
+				"Copy-Item -Force -Path 'C:/Windows/Temp/goss-0.4.2-windows-amd64.exe' -Destination 'C:/Program Files/goss/goss.exe'",
+				"Copy-Item -Force -Path 'C:/Windows/Temp/goss-spec.yaml' -Destination 'C:/ProgramData/goss/goss.yaml'",
+				"[Environment]::SetEnvironmentVariable('GOSS_USE_ALPHA', '1', 'Machine')",
+				`-Argument '--gossfile "C:/ProgramData/goss/goss.yaml" serve --listen-addr :8080'`,
+				"Register-ScheduledTask -Force -TaskName 'goss-serve'",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &Provisioner{config: tt.config}
+			got := p.persistCmd()
+			if script, ok := decodedScript(got); ok {
+				got = script
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("Provisioner.persistCmd() doesn't contain %q:\n%s", want, got)
+				}
+			}
+			for _, reject := range tt.reject {
+				if strings.Contains(got, reject) {
+					t.Errorf("Provisioner.persistCmd() contains %q:\n%s", reject, got)
+				}
+			}
+		})
+	}
+}
+
+func TestProvisioner_persist(t *testing.T) {
+	tests := []struct {
+		name       string
+		exitStatus int
+		wantErr    bool
+	}{
+		{name: "persisted", exitStatus: 0, wantErr: false},
+		{name: "failed", exitStatus: 1, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &Provisioner{
+				config: GossConfig{TargetOs: linux, Persist: &PersistConfig{}},
+			}
+			comm := &packer.MockCommunicator{StartExitStatus: tt.exitStatus}
+			if err := p.persist(packer.TestUi(t), comm); (err != nil) != tt.wantErr {
+				t.Errorf("Provisioner.persist() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if comm.StartCmd.Command != p.persistCmd() {
+				t.Errorf("ran %q, want %q", comm.StartCmd.Command, p.persistCmd())
+			}
+		})
+	}
+}
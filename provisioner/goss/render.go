@@ -0,0 +1,227 @@
+package goss
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// renderResults renders the JSON results of goss validate in format, the way
+// goss prints them. The tests run once, every format is built from their results.
+func (p *Provisioner) renderResults(results *gossResults, format string) ([]byte, error) {
+	switch format {
+	case "silent":
+		return nil, nil
+	case "json":
+		var b bytes.Buffer
+		var err error
+		if p.hasFormatOption(format, "pretty") {
+			err = json.Indent(&b, results.raw, "", "    ")
+		} else {
+			err = json.Compact(&b, results.raw)
+		}
+		b.WriteString("\n")
+		return b.Bytes(), err
+	case "json_oneline":
+		var b bytes.Buffer
+		err := json.Compact(&b, results.raw)
+		b.WriteString("\n")
+		return b.Bytes(), err
+	case "rspecish":
+		return renderRspecish(results), nil
+	case "documentation":
+		return renderDocumentation(results), nil
+	case "junit":
+		return renderJunit(results)
+	case "tap":
+		return renderTap(results), nil
+	case "nagios", "nagios_verbose":
+		return renderNagios(results, p.hasFormatOption(format, "perfdata"),
+			format == "nagios_verbose" || p.hasFormatOption(format, "verbose")), nil
+	}
+	return nil, fmt.Errorf("unknown format %s", format)
+}
+
+// hasFormatOption reports whether option is configured and supported by format
+func (p *Provisioner) hasFormatOption(format, option string) bool {
+	return oneOf(option, p.config.FormatOptions) && supportsOption(format, option)
+}
+
+// seconds formats a goss duration in nanoseconds
+func seconds(duration int64) string {
+	return fmt.Sprintf("%.3fs", time.Duration(duration).Seconds())
+}
+
+// humanResult describes a failed or skipped test
+func humanResult(r gossResult) string {
+	switch {
+	case r.Result == gossResultSkip:
+		return fmt.Sprintf("%s: %s: %s: skipped", r.ResourceType, r.ResourceID, r.Property)
+	case r.Human != "":
+		return fmt.Sprintf("%s: %s: %s:\n%s", r.ResourceType, r.ResourceID, r.Property, r.Human)
+	}
+	return r.SummaryLine
+}
+
+// writeFailedOrSkipped writes the failed and skipped tests with their title and meta
+func writeFailedOrSkipped(b *bytes.Buffer, results *gossResults) {
+	var failedOrSkipped []gossResult
+	for _, r := range results.Results {
+		if r.Result == gossResultFail || r.Result == gossResultSkip {
+			failedOrSkipped = append(failedOrSkipped, r)
+		}
+	}
+	if len(failedOrSkipped) == 0 {
+		return
+	}
+
+	b.WriteString("Failures/Skipped:\n\n")
+	for _, r := range failedOrSkipped {
+		if r.Title != "" {
+			fmt.Fprintf(b, "Title: %s\n", r.Title)
+		}
+		if len(r.Meta) != 0 {
+			keys := make([]string, 0, len(r.Meta))
+			for key := range r.Meta {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			b.WriteString("Meta:\n")
+			for _, key := range keys {
+				fmt.Fprintf(b, "    %s: %v\n", key, r.Meta[key])
+			}
+		}
+		fmt.Fprintf(b, "%s\n\n", humanResult(r))
+	}
+}
+
+func writeSummary(b *bytes.Buffer, results *gossResults) {
+	fmt.Fprintf(b, "Total Duration: %s\n", seconds(results.Summary.TotalDuration))
+	fmt.Fprintf(b, "Count: %d, Failed: %d, Skipped: %d\n",
+		results.Summary.TestCount, results.Summary.FailedCount, results.Summary.SkippedCount)
+}
+
+func renderRspecish(results *gossResults) []byte {
+	var b bytes.Buffer
+	for _, r := range results.Results {
+		switch r.Result {
+		case gossResultFail:
+			b.WriteString("F")
+		case gossResultSkip:
+			b.WriteString("S")
+		default:
+			b.WriteString(".")
+		}
+	}
+	b.WriteString("\n\n")
+	writeFailedOrSkipped(&b, results)
+	writeSummary(&b, results)
+	return b.Bytes()
+}
+
+func renderDocumentation(results *gossResults) []byte {
+	var b bytes.Buffer
+	for _, r := range results.Results {
+		fmt.Fprintf(&b, "%s\n", r.SummaryLine)
+	}
+	b.WriteString("\n\n")
+	writeFailedOrSkipped(&b, results)
+	writeSummary(&b, results)
+	return b.Bytes()
+}
+
+type junitSkipped struct{}
+
+type junitTestcase struct {
+	Name      string        `xml:"name,attr"`
+	Time      string        `xml:"time,attr"`
+	Skipped   *junitSkipped `xml:"skipped"`
+	Failure   string        `xml:"failure,omitempty"`
+	SystemOut string        `xml:"system-out"`
+}
+
+type junitTestsuite struct {
+	XMLName   xml.Name        `xml:"testsuite"`
+	Name      string          `xml:"name,attr"`
+	Errors    int             `xml:"errors,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	Time      string          `xml:"time,attr"`
+	Testcases []junitTestcase `xml:"testcase"`
+}
+
+func renderJunit(results *gossResults) ([]byte, error) {
+	suite := junitTestsuite{
+		Name:     "goss",
+		Tests:    results.Summary.TestCount,
+		Failures: results.Summary.FailedCount,
+		Skipped:  results.Summary.SkippedCount,
+		Time:     fmt.Sprintf("%.3f", time.Duration(results.Summary.TotalDuration).Seconds()),
+	}
+	for _, r := range results.Results {
+		testcase := junitTestcase{
+			Name:      fmt.Sprintf("%s %s %s", r.ResourceType, r.ResourceID, r.Property),
+			Time:      fmt.Sprintf("%.3f", time.Duration(r.Duration).Seconds()),
+			SystemOut: r.SummaryLine,
+		}
+		switch r.Result {
+		case gossResultFail:
+			testcase.Failure = r.SummaryLine
+		case gossResultSkip:
+			testcase.Skipped = &junitSkipped{}
+		}
+		suite.Testcases = append(suite.Testcases, testcase)
+	}
+
+	out, err := xml.MarshalIndent(suite, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(append([]byte(xml.Header), out...), '\n'), nil
+}
+
+func renderTap(results *gossResults) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "1..%d\n", len(results.Results))
+	for i, r := range results.Results {
+		switch r.Result {
+		case gossResultFail:
+			fmt.Fprintf(&b, "not ok %d - %s\n", i+1, r.SummaryLine)
+		case gossResultSkip:
+			fmt.Fprintf(&b, "ok %d - # SKIP %s\n", i+1, r.SummaryLine)
+		default:
+			fmt.Fprintf(&b, "ok %d - %s\n", i+1, r.SummaryLine)
+		}
+	}
+	return b.Bytes()
+}
+
+func renderNagios(results *gossResults, perfdata, verbose bool) []byte {
+	var b bytes.Buffer
+	status := "OK"
+	if results.Summary.FailedCount != 0 {
+		status = "CRITICAL"
+	}
+	duration := seconds(results.Summary.TotalDuration)
+	fmt.Fprintf(&b, "GOSS %s - Count: %d, Failed: %d, Skipped: %d, Duration: %s", status,
+		results.Summary.TestCount, results.Summary.FailedCount, results.Summary.SkippedCount, duration)
+	if perfdata {
+		fmt.Fprintf(&b, "|total=%d failed=%d skipped=%d duration=%s",
+			results.Summary.TestCount, results.Summary.FailedCount, results.Summary.SkippedCount, duration)
+	}
+	b.WriteString("\n")
+	if verbose {
+		failed := 0
+		for _, r := range results.Results {
+			if r.Result == gossResultFail {
+				failed++
+				fmt.Fprintf(&b, "Fail %d - %s\n", failed, r.SummaryLine)
+			}
+		}
+	}
+	return b.Bytes()
+}
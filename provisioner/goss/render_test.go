@@ -0,0 +1,124 @@
+package goss
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+const renderResults = `{
+  "results": [
+    {"resource-type": "File", "resource-id": "/etc/motd", "property": "exists", "title": "motd", "meta": {"severity": "warn"}, "result": 1, "human": "Expected\n    false\nto equal\n    true", "summary-line": "File: /etc/motd: exists: doesn't match, expect: [true] found: [false]", "duration": 1000000},
+    {"resource-type": "User", "resource-id": "root", "property": "exists", "result": 0, "summary-line": "User: root: exists: matches expectation: [true]", "duration": 2000000},
+    {"resource-type": "Port", "resource-id": "tcp:22", "property": "listening", "result": 2, "summary-line": "Port: tcp:22: listening: skipped"}
+  ],
+  "summary": {"failed-count": 1, "skipped-count": 1, "test-count": 3, "total-duration": 12000000}
+}`
+
+func TestProvisioner_renderResults(t *testing.T) {
+	tests := []struct {
+		format  string
+		options []string
+		want    []string
+	}{
+		{
+			format: "rspecish",
+			want: []string{
+				"F.S\n\nFailures/Skipped:\n\nTitle: motd\nMeta:\n    severity: warn\nFile: /etc/motd: exists:\nExpected\n",
+				"Port: tcp:22: listening: skipped\n\n",
+				"Total Duration: 0.012s\nCount: 3, Failed: 1, Skipped: 1\n",
+			},
+		},
+		{
+			format: "documentation",
+			want: []string{
+				"User: root: exists: matches expectation: [true]\n",
+				"Failures/Skipped:",
+				"Count: 3, Failed: 1, Skipped: 1\n",
+			},
+		},
+		{
+			format: "json",
+			want:   []string{`{"results":[{"resource-type":"File"`},
+		},
+		{
+			format:  "json",
+			options: []string{"pretty"},
+			want:    []string{"{\n    \"results\": [\n"},
+		},
+		{
+			format: "json_oneline",
+			want:   []string{`"summary":{"failed-count":1`},
+		},
+		{
+			format: "junit",
+			want: []string{
+				`<testsuite name="goss" errors="0" tests="3" failures="1" skipped="1" time="0.012">`,
+				`<testcase name="File /etc/motd exists" time="0.001">`,
+				"<skipped></skipped>",
+			},
+		},
+		{
+			format: "tap",
+			want: []string{
+				"1..3\nnot ok 1 - File: /etc/motd: exists: doesn't match",
+				"ok 2 - User: root",
+				"ok 3 - # SKIP Port: tcp:22",
+			},
+		},
+		{
+			format:  "nagios",
+			options: []string{"perfdata"},
+			want:    []string{"GOSS CRITICAL - Count: 3, Failed: 1, Skipped: 1, Duration: 0.012s|total=3 failed=1 skipped=1 duration=0.012s\n"},
+		},
+		{
+			format: "nagios_verbose",
+			want:   []string{"\nFail 1 - File: /etc/motd: exists: doesn't match"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format+strings.Join(tt.options, ","), func(t *testing.T) {
+			results, err := parseResults(renderResults)
+			if err != nil {
+				t.Fatal(err)
+			}
+			p := &Provisioner{config: GossConfig{FormatOptions: tt.options}}
+			got, err := p.renderResults(results, tt.format)
+			if err != nil {
+				t.Fatalf("Provisioner.renderResults() error = %v", err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(string(got), want) {
+					t.Errorf("Provisioner.renderResults() = %q, want %q", got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestProvisioner_runGossCmdOutputFormat(t *testing.T) {
+	p := &Provisioner{
+		config: GossConfig{
+			Format:       []string{"tap"},
+			MetricsFile:  "goss.prom",
+			SkipDownload: true,
+		},
+	}
+	comm := &packer.MockCommunicator{
+		StartStdout:     renderResults,
+		StartExitStatus: 1,
+	}
+	var out bytes.Buffer
+	ui := &packer.BasicUi{Reader: new(bytes.Buffer), Writer: &out, ErrorWriter: &out}
+	if err := p.runGossCmd(ui, comm, &packer.RemoteCmd{Command: "goss validate -f json"}, "validate"); err == nil {
+		t.Errorf("Provisioner.runGossCmd() error = nil, want failed tests")
+	}
+	if p.results == nil || p.results.Summary.FailedCount != 1 {
+		t.Errorf("Provisioner.runGossCmd() didn't read the results")
+	}
+	if !strings.Contains(out.String(), "not ok 1 - File: /etc/motd") || strings.Contains(out.String(), `"results"`) {
+		t.Errorf("log = %q, want the tap results", out.String())
+	}
+}
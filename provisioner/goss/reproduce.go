@@ -0,0 +1,237 @@
+package goss
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+const (
+	reproduceScriptSh = "reproduce-goss.sh"
+	reproduceScriptPs = "reproduce-goss.ps1"
+)
+
+// shQuote quotes s as a single quoted sh string
+func shQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+// reproduceScriptName returns the file name of the reproduction script for the remote shell
+func (p *Provisioner) reproduceScriptName() string {
+	if p.shell() != shellPosix {
+		return reproduceScriptPs
+	}
+	return reproduceScriptSh
+}
+
+// reproduceSteps returns the remote commands and uploads replaying the goss validate run
+func (p *Provisioner) reproduceSteps() ([]string, []upload, error) {
+	plan, err := p.uploadPlan()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	root := filepath.ToSlash(p.config.RemotePath)
+	commands := []string{p.mkDir(root)}
+	created := map[string]bool{root: true}
+	uploads := make([]upload, 0, len(plan))
+	for _, u := range plan {
+		if dir := path.Dir(u.dst); !created[dir] {
+			commands = append(commands, p.mkDir(dir))
+			created[dir] = true
+		}
+		src, err := filepath.Abs(u.src)
+		if err != nil {
+			return nil, nil, err
+		}
+		uploads = append(uploads, upload{src: src, dst: u.dst})
+	}
+	return commands, uploads, nil
+}
+
+const (
+	// The secrets of the build are read from these environment variables
+	// of the reproduction script instead of being written to it
+	reproducePasswordEnv         = "GOSS_PASSWORD"
+	reproduceElevatedPasswordEnv = "GOSS_ELEVATED_PASSWORD"
+
+	// passwordPlaceholder stands in for password while a command is quoted
+	passwordPlaceholder = "@@" + reproducePasswordEnv + "@@"
+)
+
+// psRunner are the PowerShell functions of the reproduction script running commands
+// in the remoting session. Elevated commands run in a scheduled task like the
+// elevated runner of the build does.
+const psRunner = `function ConvertTo-EncodedCommand([string]$script) {
+    'powershell -NoProfile -NonInteractive -ExecutionPolicy Bypass -EncodedCommand ' +
+        [Convert]::ToBase64String([Text.Encoding]::Unicode.GetBytes($script))
+}
+function Invoke-Remote([string]$command, [switch]$Elevated) {
+    if ($Elevated) {
+        $code = Invoke-Command -Session $session -ScriptBlock {
+            param($c, $user, $password)
+            $name = 'goss-reproduce-' + [guid]::NewGuid()
+            $script = Join-Path $env:SystemRoot "Temp\$name.cmd"
+            $log = Join-Path $env:SystemRoot "Temp\$name.log"
+            Set-Content -Encoding ascii -Path $script -Value "@($c) > ""$log"" 2>&1"
+            $action = New-ScheduledTaskAction -Execute 'cmd.exe' -Argument "/c ""$script"""
+            Register-ScheduledTask -TaskName $name -Action $action -User $user -Password $password -RunLevel Highest | Out-Null
+            Start-ScheduledTask -TaskName $name
+            do { Start-Sleep -Seconds 1 } while ((Get-ScheduledTask -TaskName $name).State -eq 'Running')
+            $code = (Get-ScheduledTaskInfo -TaskName $name).LastTaskResult
+            Unregister-ScheduledTask -TaskName $name -Confirm:$false
+            Get-Content $log | Out-Host
+            Remove-Item $script, $log
+            $code
+        } -ArgumentList $command, $ElevatedUser, $env:` + reproduceElevatedPasswordEnv + `
+    } else {
+        $code = Invoke-Command -Session $session -ScriptBlock { param($c) cmd.exe /c $c | Out-Host; $LASTEXITCODE } -ArgumentList $command
+    }
+    if ($code -ne 0) { throw "$command failed with exit code $code" }
+}
+`
+
+// withPlaceholder replaces the download password in cmd by passwordPlaceholder
+func (p *Provisioner) withPlaceholder(cmd string) string {
+	if p.config.Password == "" {
+		return cmd
+	}
+	return strings.Replace(cmd, p.config.Password, passwordPlaceholder, -1)
+}
+
+// shArg quotes cmd as an argument of ssh, reading the password from the environment
+func (p *Provisioner) shArg(cmd string) string {
+	return strings.Replace(shQuote(p.withPlaceholder(cmd)), passwordPlaceholder,
+		fmt.Sprintf(`'"$%s"'`, reproducePasswordEnv), -1)
+}
+
+// psArg quotes cmd as an argument of Invoke-Remote, reading the password from the
+// environment. Encoded PowerShell commands are decoded and encoded again when the
+// script runs, so the password can be filled in.
+func (p *Provisioner) psArg(cmd string) string {
+	script, encoded := decodedScript(cmd)
+	if !encoded {
+		script = cmd
+	}
+	arg := strings.Replace(psQuote(p.withPlaceholder(script)), passwordPlaceholder,
+		fmt.Sprintf("' + $env:%s + '", reproducePasswordEnv), -1)
+	if encoded {
+		return fmt.Sprintf("(ConvertTo-EncodedCommand (%s))", arg)
+	}
+	return fmt.Sprintf("(%s)", arg)
+}
+
+// reproduceScript returns a script replaying the goss run against another host, from
+// installing goss to the render and validate phases runGoss runs. The sh form uses
+// ssh and scp, the PowerShell form a PowerShell remoting session.
+func (p *Provisioner) reproduceScript() (string, error) {
+	mkdirs, uploads, err := p.reproduceSteps()
+	if err != nil {
+		return "", err
+	}
+
+	var caUpload *upload
+	var install []string
+	if !p.config.SkipInstall {
+		if p.config.CABundle != "" {
+			src, err := filepath.Abs(p.config.CABundle)
+			if err != nil {
+				return "", err
+			}
+			caUpload = &upload{src: src, dst: p.caBundlePath()}
+		}
+		install = []string{p.downloadCmd(), p.dialect().install(p.config.DownloadPath)}
+	}
+	cmds := p.gossCmds()
+
+	var sb strings.Builder
+	if p.reproduceScriptName() == reproduceScriptSh {
+		sb.WriteString("#!/bin/sh\n")
+		sb.WriteString("# Replays the goss run of packer-provisioner-goss\n")
+		sb.WriteString("# Usage: ./" + reproduceScriptSh + " user@host\n")
+		sb.WriteString("set -e\n")
+		sb.WriteString("target=\"${1:?usage: $0 user@host}\"\n")
+		if p.config.Password != "" && !p.config.SkipInstall {
+			sb.WriteString(fmt.Sprintf(": \"${%s:?set %s to the password of the goss download}\"\n",
+				reproducePasswordEnv, reproducePasswordEnv))
+		}
+		sb.WriteString("\n")
+		for _, cmd := range mkdirs {
+			sb.WriteString(fmt.Sprintf("ssh \"$target\" %s\n", p.shArg(cmd)))
+		}
+		if caUpload != nil {
+			sb.WriteString(fmt.Sprintf("scp %s \"$target\":%s\n", shQuote(caUpload.src), shQuote(caUpload.dst)))
+		}
+		for _, cmd := range install {
+			sb.WriteString(fmt.Sprintf("ssh \"$target\" %s\n", p.shArg(cmd)))
+		}
+		if caUpload != nil {
+			sb.WriteString(fmt.Sprintf("ssh \"$target\" %s\n", p.shArg(p.dialect().remove(caUpload.dst))))
+		}
+		for _, u := range uploads {
+			sb.WriteString(fmt.Sprintf("scp %s \"$target\":%s\n", shQuote(u.src), shQuote(u.dst)))
+		}
+		for _, phase := range gossPhases {
+			sb.WriteString(fmt.Sprintf("ssh \"$target\" %s\n", p.shArg(cmds[phase])))
+		}
+	} else {
+		sb.WriteString("# Replays the goss run of packer-provisioner-goss\n")
+		sb.WriteString("# Usage: .\\" + reproduceScriptPs + " -ComputerName host [-Credential (Get-Credential)]\n")
+		sb.WriteString("param(\n    [Parameter(Mandatory = $true)][string]$ComputerName,\n    [pscredential]$Credential\n)\n")
+		sb.WriteString("$ErrorActionPreference = 'Stop'\n")
+		if p.config.Password != "" && !p.config.SkipInstall {
+			sb.WriteString(fmt.Sprintf("if (-not $env:%s) { throw 'Set %s to the password of the goss download' }\n",
+				reproducePasswordEnv, reproducePasswordEnv))
+		}
+		elevated := ""
+		if p.elevated() {
+			elevated = " -Elevated"
+			sb.WriteString(fmt.Sprintf("if (-not $env:%s) { throw 'Set %s to the password of the elevated user' }\n",
+				reproduceElevatedPasswordEnv, reproduceElevatedPasswordEnv))
+			sb.WriteString(fmt.Sprintf("$ElevatedUser = %s\n", psQuote(p.config.ElevatedUser)))
+		}
+		sb.WriteString("$params = @{ ComputerName = $ComputerName }\n")
+		sb.WriteString("if ($Credential) { $params.Credential = $Credential }\n")
+		sb.WriteString("$session = New-PSSession @params\n")
+		sb.WriteString(psRunner)
+		sb.WriteString("\n")
+		for _, cmd := range mkdirs {
+			sb.WriteString(fmt.Sprintf("Invoke-Remote %s\n", p.psArg(cmd)))
+		}
+		if caUpload != nil {
+			sb.WriteString(fmt.Sprintf("Copy-Item -ToSession $session -Path %s -Destination %s\n", psQuote(caUpload.src), psQuote(caUpload.dst)))
+		}
+		for _, cmd := range install {
+			sb.WriteString(fmt.Sprintf("Invoke-Remote %s\n", p.psArg(cmd)))
+		}
+		if caUpload != nil {
+			sb.WriteString(fmt.Sprintf("Invoke-Remote %s\n", p.psArg(p.dialect().remove(caUpload.dst))))
+		}
+		for _, u := range uploads {
+			sb.WriteString(fmt.Sprintf("Copy-Item -ToSession $session -Path %s -Destination %s\n", psQuote(u.src), psQuote(u.dst)))
+		}
+		for _, phase := range gossPhases {
+			sb.WriteString(fmt.Sprintf("Invoke-Remote %s%s\n", p.psArg(cmds[phase]), elevated))
+		}
+		sb.WriteString("Remove-PSSession $session\n")
+	}
+
+	return sb.String(), nil
+}
+
+// writeReproduceScript writes the reproduction script to the current directory
+func (p *Provisioner) writeReproduceScript(ui packer.Ui) {
+	script, err := p.reproduceScript()
+	if err == nil {
+		err = os.WriteFile(p.reproduceScriptName(), []byte(script), 0755)
+	}
+	if err != nil {
+		ui.Error(fmt.Sprintf("Error writing %s: %s", p.reproduceScriptName(), err))
+		return
+	}
+	ui.Message(fmt.Sprintf("Wrote %s to replay the goss run", p.reproduceScriptName()))
+}
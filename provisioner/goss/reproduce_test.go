@@ -0,0 +1,169 @@
+package goss
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+func Test_shQuote(t *testing.T) {
+	tests := []struct {
+		name string
+		s    string
+		want string
+	}{
+		{
+			name: "plain",
+			s:    "mkdir -p /tmp/goss",
+			want: "'mkdir -p /tmp/goss'",
+		},
+		{
+			name: "single quotes",
+			s:    "mkdir -p '/tmp/goss'",
+			want: `'mkdir -p '\''/tmp/goss'\'''`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := shQuote(tt.s); got != tt.want {
+				t.Errorf("shQuote() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProvisioner_reproduceScript(t *testing.T) {
+	dir := writeTree(t, "goss.yaml", "tests/nginx.yaml")
+	abs := func(rel string) string {
+		return filepath.Join(dir, filepath.FromSlash(rel))
+	}
+
+	tests := []struct {
+		name     string
+		config   GossConfig
+		connType string
+		wantName string
+		want     []string
+	}{
+		{
+			name: "linux",
+			config: GossConfig{
+				TargetOs:      linux,
+				Tests:         []string{abs("goss.yaml"), abs("tests")},
+				RemotePath:    "/tmp/goss",
+				DownloadPath:  "/tmp/goss-0.4.2-linux-amd64",
+				URL:           "https://example.com/goss",
+				Username:      "mirror",
+				Password:      "s3cret",
+				PreservePaths: false,
+			},
+			wantName: reproduceScriptSh,
+			want: []string{
+				"#!/bin/sh",
+				`ssh "$target" 'mkdir -p '\''/tmp/goss'\'''`,
+				`ssh "$target" 'mkdir -p '\''/tmp/goss/tests'\'''`,
+				"chmod 555 /tmp/goss-0.4.2-linux-amd64",
+				`scp '` + abs("goss.yaml") + `' "$target":'/tmp/goss/goss.yaml'`,
+				`scp '` + abs("tests/nginx.yaml") + `' "$target":'/tmp/goss/tests/nginx.yaml'`,
+				"render > /tmp/goss-spec.yaml",
+				"render -d > /tmp/debug-goss-spec.yaml",
+				"validate --retry-timeout 0s --sleep 1s",
+				`: "${GOSS_PASSWORD:?set GOSS_PASSWORD`,
+				`-u mirror:'"$GOSS_PASSWORD"'`,
+			},
+		},
+		{
+			name: "windows",
+			config: GossConfig{
+				TargetOs:     windows,
+				Tests:        []string{abs("goss.yaml")},
+				RemotePath:   "C:/Windows/Temp/goss",
+				DownloadPath: "C:/Windows/Temp/goss-0.4.2-windows-amd64.exe",
+				SkipInstall:  true,
+			},
+			connType: "winrm",
+			wantName: reproduceScriptPs,
+			want: []string{
+				"$session = New-PSSession @params",
+				"Copy-Item -ToSession $session -Path '" + abs("goss.yaml") + "' -Destination 'C:/Windows/Temp/goss/goss.yaml'",
+				"Invoke-Remote (ConvertTo-EncodedCommand ('Set-Location -Path ''C:/Windows/Temp/goss''; & ''C:/Windows/Temp/goss-0.4.2-windows-amd64.exe''",
+				" render | Out-File",
+				" validate --retry-timeout 0s",
+			},
+		},
+		{
+			name: "windows elevated",
+			config: GossConfig{
+				TargetOs:         windows,
+				Tests:            []string{abs("goss.yaml")},
+				RemotePath:       "C:/Windows/Temp/goss",
+				DownloadPath:     "C:/Windows/Temp/goss-0.4.2-windows-amd64.exe",
+				URL:              "https://example.com/goss.exe",
+				Username:         "mirror",
+				Password:         "s3cret",
+				ElevatedUser:     "Administrator",
+				ElevatedPassword: "Passw0rd",
+			},
+			connType: "winrm",
+			wantName: reproduceScriptPs,
+			want: []string{
+				"if (-not $env:GOSS_PASSWORD)",
+				"if (-not $env:GOSS_ELEVATED_PASSWORD)",
+				"$ElevatedUser = 'Administrator'",
+				"-ArgumentList $command, $ElevatedUser, $env:GOSS_ELEVATED_PASSWORD",
+				"-u mirror:' + $env:GOSS_PASSWORD + '",
+				" validate --retry-timeout 0s --sleep 1s  ; exit $LASTEXITCODE')) -Elevated",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &Provisioner{
+				config:   tt.config,
+				connType: tt.connType,
+			}
+			if got := p.reproduceScriptName(); got != tt.wantName {
+				t.Errorf("Provisioner.reproduceScriptName() = %v, want %v", got, tt.wantName)
+			}
+			got, err := p.reproduceScript()
+			if err != nil {
+				t.Fatalf("Provisioner.reproduceScript() error = %v", err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("Provisioner.reproduceScript() doesn't contain %q:\n%s", want, got)
+				}
+			}
+			for _, secret := range []string{tt.config.Password, tt.config.ElevatedPassword} {
+				if secret != "" && strings.Contains(got, secret) {
+					t.Errorf("Provisioner.reproduceScript() contains a password:\n%s", got)
+				}
+			}
+		})
+	}
+}
+
+func TestProvisioner_writeReproduceScript(t *testing.T) {
+	dir := writeTree(t, "goss.yaml")
+	chdir(t, t.TempDir())
+
+	p := &Provisioner{
+		config: GossConfig{
+			TargetOs:   linux,
+			Tests:      []string{filepath.Join(dir, "goss.yaml")},
+			RemotePath: "/tmp/goss",
+		},
+	}
+	p.writeReproduceScript(packer.TestUi(t))
+
+	info, err := os.Stat(reproduceScriptSh)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm()&0100 == 0 {
+		t.Errorf("%s is not executable", reproduceScriptSh)
+	}
+}
@@ -0,0 +1,150 @@
+package goss
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+const (
+	severityFail   = "fail"
+	severityWarn   = "warn"
+	severityIgnore = "ignore"
+
+	defaultSeverityKey = "severity"
+	// severityDefault is the policy entry for failed tests without a known severity
+	severityDefault = "default"
+
+	// Results of failed and skipped tests in the goss JSON output
+	gossResultFail = 1
+	gossResultSkip = 2
+)
+
+var validSeverityActions = []string{severityFail, severityWarn, severityIgnore}
+
+// gossResult is a single test result in the JSON output of goss validate
+type gossResult struct {
+	ResourceType string                 `json:"resource-type"`
+	ResourceID   string                 `json:"resource-id"`
+	Property     string                 `json:"property"`
+	Title        string                 `json:"title"`
+	Meta         map[string]interface{} `json:"meta"`
+	Result       int                    `json:"result"`
+	SummaryLine  string                 `json:"summary-line"`
+	Human        string                 `json:"human"`
+	Duration     int64                  `json:"duration"`
+}
+
+// gossResults is the JSON output of goss validate
+type gossResults struct {
+	Results []gossResult `json:"results"`
+	Summary struct {
+		FailedCount   int    `json:"failed-count"`
+		SkippedCount  int    `json:"skipped-count"`
+		TestCount     int    `json:"test-count"`
+		SummaryLine   string `json:"summary-line"`
+		TotalDuration int64  `json:"total-duration"`
+	} `json:"summary"`
+	// raw is the JSON document goss printed
+	raw []byte
+}
+
+// parseResults reads the JSON output of goss validate. Output of the
+// remote shell around the JSON document, e.g. of the elevated runner, is ignored.
+func parseResults(out string) (*gossResults, error) {
+	start := strings.Index(out, "{")
+	end := strings.LastIndex(out, "}")
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("no JSON results in goss output")
+	}
+
+	var results gossResults
+	raw := []byte(out[start : end+1])
+	if err := json.Unmarshal(raw, &results); err != nil {
+		return nil, err
+	}
+	results.raw = raw
+	if results.Results == nil {
+		return nil, fmt.Errorf("no results in goss output")
+	}
+	return &results, nil
+}
+
+// severityKey returns the meta key failed tests are grouped by
+func (p *Provisioner) severityKey() string {
+	if p.config.SeverityKey == "" {
+		return defaultSeverityKey
+	}
+	return p.config.SeverityKey
+}
+
+// severityAction returns what a failed test of severity does to the build
+func (p *Provisioner) severityAction(severity string) string {
+	if action, ok := p.config.SeverityPolicy[severity]; ok && severity != "" {
+		return action
+	}
+	if action, ok := p.config.SeverityPolicy[severityDefault]; ok {
+		return action
+	}
+	return severityFail
+}
+
+// severity returns the value of the severity meta key of the result
+func (p *Provisioner) severity(result gossResult) string {
+	if value, ok := result.Meta[p.severityKey()]; ok && value != nil {
+		return fmt.Sprint(value)
+	}
+	return ""
+}
+
+// applySeverityPolicy groups the failed tests by severity and fails the
+// build when a group with the fail action isn't empty
+func (p *Provisioner) applySeverityPolicy(ui packer.Ui, results *gossResults) error {
+	groups := make(map[string][]gossResult)
+	for _, result := range results.Results {
+		if result.Result == gossResultFail {
+			severity := p.severity(result)
+			groups[severity] = append(groups[severity], result)
+		}
+	}
+
+	severities := make([]string, 0, len(groups))
+	for severity := range groups {
+		severities = append(severities, severity)
+	}
+	sort.Strings(severities)
+
+	var failing []string
+	for _, severity := range severities {
+		failed := groups[severity]
+		name := severity
+		if name == "" {
+			name = fmt.Sprintf("no %s", p.severityKey())
+		}
+
+		action := p.severityAction(severity)
+		header := fmt.Sprintf("%d failed tests with %s (%s)", len(failed), name, action)
+		switch action {
+		case severityIgnore:
+			ui.Message(header)
+			continue
+		case severityWarn:
+			ui.Error("Warning: " + header)
+		default:
+			ui.Error(header)
+			failing = append(failing, fmt.Sprintf("%d %s", len(failed), name))
+		}
+		for _, result := range failed {
+			ui.Message(fmt.Sprintf("  %s", strings.SplitN(result.SummaryLine, "\n", 2)[0]))
+		}
+	}
+
+	if len(failing) != 0 {
+		return fmt.Errorf("goss tests failed: %s", strings.Join(failing, ", "))
+	}
+	ui.Say("Goss validate passed the severity policy")
+	return nil
+}
@@ -0,0 +1,149 @@
+package goss
+
+import (
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+const severityResults = `{
+  "results": [
+    {"resource-type": "Service", "resource-id": "nginx", "property": "running", "meta": {"severity": "critical"}, "result": 1, "summary-line": "Service: nginx: running: Expected\n    <bool>: false\nto equal\n    <bool>: true"},
+    {"resource-type": "File", "resource-id": "/etc/motd", "property": "exists", "meta": {"severity": "warn"}, "result": 1, "summary-line": "File: /etc/motd: exists: Expected false to equal true"},
+    {"resource-type": "Package", "resource-id": "vim", "property": "installed", "meta": {"severity": "info"}, "result": 1, "summary-line": "Package: vim: installed: Expected false to equal true"},
+    {"resource-type": "User", "resource-id": "root", "property": "exists", "meta": {"severity": "critical"}, "result": 0, "summary-line": "User: root: exists: matches expectation: [true]"},
+    {"resource-type": "Port", "resource-id": "tcp:22", "property": "listening", "result": 2, "summary-line": "Port: tcp:22: listening: skipped"}
+  ],
+  "summary": {"failed-count": 3, "test-count": 5, "summary-line": "Count: 5, Failed: 3, Skipped: 1"}
+}`
+
+func Test_parseResults(t *testing.T) {
+	tests := []struct {
+		name       string
+		out        string
+		wantFailed int
+		wantErr    bool
+	}{
+		{
+			name:       "results",
+			out:        severityResults,
+			wantFailed: 3,
+		},
+		{
+			name:       "surrounded by shell output",
+			out:        "#< CLIXML\n" + severityResults + "\r\n",
+			wantFailed: 3,
+		},
+		{
+			name:    "rspecish",
+			out:     "Count: 5, Failed: 3, Skipped: 1",
+			wantErr: true,
+		},
+		{
+			name:    "other JSON",
+			out:     `{"error": "open goss.yaml: no such file or directory"}`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseResults(tt.out)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseResults() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && got.Summary.FailedCount != tt.wantFailed {
+				t.Errorf("parseResults() failed-count = %v, want %v", got.Summary.FailedCount, tt.wantFailed)
+			}
+		})
+	}
+}
+
+func TestProvisioner_applySeverityPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  GossConfig
+		wantErr bool
+	}{
+		{
+			name: "fail on critical",
+			config: GossConfig{SeverityPolicy: map[string]string{
+				"critical": severityFail,
+				"warn":     severityWarn,
+				"info":     severityIgnore,
+			}},
+			wantErr: true,
+		},
+		{
+			name: "only warnings",
+			config: GossConfig{SeverityPolicy: map[string]string{
+				"critical": severityWarn,
+				"warn":     severityWarn,
+				"info":     severityIgnore,
+			}},
+			wantErr: false,
+		},
+		{
+			name: "unlisted severity fails by default",
+			config: GossConfig{SeverityPolicy: map[string]string{
+				"critical": severityWarn,
+				"warn":     severityWarn,
+			}},
+			wantErr: true,
+		},
+		{
+			name: "default entry",
+			config: GossConfig{SeverityPolicy: map[string]string{
+				"critical":      severityWarn,
+				severityDefault: severityIgnore,
+			}},
+			wantErr: false,
+		},
+		{
+			name: "other key",
+			config: GossConfig{
+				SeverityKey:    "level",
+				SeverityPolicy: map[string]string{"critical": severityFail, severityDefault: severityWarn},
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := parseResults(severityResults)
+			if err != nil {
+				t.Fatal(err)
+			}
+			p := &Provisioner{
+				config: tt.config,
+			}
+			if err := p.applySeverityPolicy(packer.TestUi(t), results); (err != nil) != tt.wantErr {
+				t.Errorf("Provisioner.applySeverityPolicy() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestProvisioner_runGossCmdSeverity(t *testing.T) {
+	p := &Provisioner{
+		config: GossConfig{SeverityPolicy: map[string]string{
+			"critical": severityWarn,
+			"warn":     severityWarn,
+			"info":     severityIgnore,
+		}},
+	}
+	comm := &packer.MockCommunicator{
+		StartStdout:     severityResults,
+		StartExitStatus: 1,
+	}
+	if err := p.runGossCmd(packer.TestUi(t), comm, &packer.RemoteCmd{Command: "goss validate"}, "validate"); err != nil {
+		t.Errorf("Provisioner.runGossCmd() error = %v", err)
+	}
+	if got := p.format(); got != "-f json" {
+		t.Errorf("Provisioner.format() = %v, want -f json", got)
+	}
+
+	p.config.Format = []string{"junit"}
+	if got := p.format(); got != "-f json" {
+		t.Errorf("Provisioner.format() with junit = %v, want -f json", got)
+	}
+}
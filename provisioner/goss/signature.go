@@ -0,0 +1,246 @@
+package goss
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/packer"
+	"golang.org/x/crypto/blake2b"
+	"golang.org/x/net/http/httpproxy"
+)
+
+const (
+	// minisignExt is appended to url when signature_url isn't set
+	minisignExt = ".minisig"
+	// minisignAlg signs the file itself, minisignAlgHashed its BLAKE2b-512 hash
+	minisignAlg       = "Ed"
+	minisignAlgHashed = "ED"
+
+	untrustedComment = "untrusted comment:"
+	trustedComment   = "trusted comment: "
+
+	// fetchTimeout bounds each download of goss and its signature on the local machine
+	fetchTimeout = 5 * time.Minute
+)
+
+// minisignKey is a minisign ed25519 public key
+type minisignKey struct {
+	id  [8]byte
+	key ed25519.PublicKey
+}
+
+// minisignSig is a detached minisign signature
+type minisignSig struct {
+	alg            string
+	id             [8]byte
+	sig            []byte
+	trustedComment string
+	// globalSig signs sig followed by the trusted comment
+	globalSig []byte
+}
+
+// keyID formats a key id the way minisign prints it
+func keyID(id [8]byte) string {
+	return fmt.Sprintf("%016X", binary.LittleEndian.Uint64(id[:]))
+}
+
+// parseMinisignKey parses a minisign public key, either the base64 key alone
+// or the content of a minisign .pub file
+func parseMinisignKey(s string) (*minisignKey, error) {
+	var encoded string
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, untrustedComment) {
+			encoded = line
+		}
+	}
+
+	b, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("not a minisign public key: %s", err)
+	}
+	if len(b) != 2+8+ed25519.PublicKeySize || string(b[:2]) != minisignAlg {
+		return nil, fmt.Errorf("not a minisign ed25519 public key")
+	}
+
+	k := &minisignKey{key: ed25519.PublicKey(b[10:])}
+	copy(k.id[:], b[2:10])
+	return k, nil
+}
+
+// parseMinisignSig parses the content of a minisign .minisig file
+func parseMinisignSig(content []byte) (*minisignSig, error) {
+	lines := strings.Split(strings.TrimSpace(strings.Replace(string(content), "\r\n", "\n", -1)), "\n")
+	if len(lines) != 4 || !strings.HasPrefix(lines[0], untrustedComment) || !strings.HasPrefix(lines[2], trustedComment) {
+		return nil, fmt.Errorf("not a minisign signature")
+	}
+
+	b, err := base64.StdEncoding.DecodeString(lines[1])
+	if err != nil {
+		return nil, fmt.Errorf("not a minisign signature: %s", err)
+	}
+	if len(b) != 2+8+ed25519.SignatureSize {
+		return nil, fmt.Errorf("not a minisign ed25519 signature")
+	}
+	globalSig, err := base64.StdEncoding.DecodeString(lines[3])
+	if err != nil || len(globalSig) != ed25519.SignatureSize {
+		return nil, fmt.Errorf("not a minisign signature: invalid trusted comment signature")
+	}
+
+	s := &minisignSig{
+		alg:            string(b[:2]),
+		sig:            b[10:],
+		trustedComment: strings.TrimPrefix(lines[2], trustedComment),
+		globalSig:      globalSig,
+	}
+	copy(s.id[:], b[2:10])
+	return s, nil
+}
+
+// verify checks data was signed by the key, including the trusted comment
+func (k *minisignKey) verify(data []byte, s *minisignSig) error {
+	if s.id != k.id {
+		return fmt.Errorf("signed with key %s, not %s", keyID(s.id), keyID(k.id))
+	}
+
+	message := data
+	switch s.alg {
+	case minisignAlg:
+	case minisignAlgHashed:
+		hash := blake2b.Sum512(data)
+		message = hash[:]
+	default:
+		return fmt.Errorf("unsupported signature algorithm %q", s.alg)
+	}
+	if !ed25519.Verify(k.key, message, s.sig) {
+		return fmt.Errorf("invalid signature")
+	}
+
+	global := append(append([]byte{}, s.sig...), s.trustedComment...)
+	if !ed25519.Verify(k.key, global, s.globalSig) {
+		return fmt.Errorf("invalid trusted comment signature")
+	}
+	return nil
+}
+
+// signatureURL returns the URL of the detached signature of the goss binary
+func (p *Provisioner) signatureURL() string {
+	if p.config.SignatureURL == "" {
+		return p.config.URL + minisignExt
+	}
+	return p.config.SignatureURL
+}
+
+// httpClient returns the client downloading goss on the local machine,
+// trusting ca_bundle and honouring skip_ssl and the proxy settings like curl
+// and wget on the remote host
+func (p *Provisioner) httpClient() (*http.Client, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: p.config.SkipSSLChk}
+	if p.config.CABundle != "" {
+		pem, err := os.ReadFile(p.config.CABundle)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", p.config.CABundle)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	proxy := p.proxyConfig().ProxyFunc()
+	transport.Proxy = func(req *http.Request) (*url.URL, error) {
+		return proxy(req.URL)
+	}
+	return &http.Client{Transport: transport, Timeout: fetchTimeout}, nil
+}
+
+// proxyConfig returns http_proxy, https_proxy and no_proxy, each falling back
+// to the environment of Packer when it isn't configured
+func (p *Provisioner) proxyConfig() *httpproxy.Config {
+	proxyConfig := httpproxy.FromEnvironment()
+	if p.config.HTTPProxy != "" {
+		proxyConfig.HTTPProxy = p.config.HTTPProxy
+	}
+	if p.config.HTTPSProxy != "" {
+		proxyConfig.HTTPSProxy = p.config.HTTPSProxy
+	}
+	if p.config.NoProxy != "" {
+		proxyConfig.NoProxy = p.config.NoProxy
+	}
+	return proxyConfig
+}
+
+// fetch downloads url on the local machine
+func (p *Provisioner) fetch(client *http.Client, url string) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if p.config.Username != "" {
+		req.SetBasicAuth(p.config.Username, p.config.Password)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", url, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// installVerified downloads goss and its signature on the local machine, verifies
+// the signature with signature_public_key and uploads the binary to download_path
+func (p *Provisioner) installVerified(ui packer.Ui, comm packer.Communicator) error {
+	if plan, ok := comm.(*dryRunPlan); ok {
+		ui.Message(fmt.Sprintf("Dry run: skipping the download and verification of %s", p.config.URL))
+		plan.record(planStep{Action: "upload", Source: p.config.URL, Destination: p.config.DownloadPath})
+		return nil
+	}
+
+	key, err := parseMinisignKey(p.config.SignaturePublicKey)
+	if err != nil {
+		return err
+	}
+	client, err := p.httpClient()
+	if err != nil {
+		return err
+	}
+
+	ui.Message(fmt.Sprintf("Downloading Goss and its signature %s", p.signatureURL()))
+	goss, err := p.fetch(client, p.config.URL)
+	if err != nil {
+		return fmt.Errorf("Unable to download Goss: %s", err)
+	}
+	content, err := p.fetch(client, p.signatureURL())
+	if err != nil {
+		return fmt.Errorf("Unable to download Goss signature: %s", err)
+	}
+	sig, err := parseMinisignSig(content)
+	if err != nil {
+		return err
+	}
+	if err := key.verify(goss, sig); err != nil {
+		return fmt.Errorf("Goss signature verification failed: %s", err)
+	}
+	ui.Message(fmt.Sprintf("Verified signature of key %s: %s", keyID(key.id), sig.trustedComment))
+
+	ui.Message(fmt.Sprintf("Uploading Goss to %s", p.config.DownloadPath))
+	return comm.Upload(p.config.DownloadPath, bytes.NewReader(goss), nil)
+}
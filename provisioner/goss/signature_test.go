@@ -0,0 +1,198 @@
+package goss
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/packer"
+	"golang.org/x/crypto/blake2b"
+)
+
+// testKeyPair generates a minisign key pair, returning the public key as in a .pub file
+func testKeyPair(t *testing.T, id string) (string, ed25519.PrivateKey) {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := append([]byte(minisignAlg+id), pub...)
+	return "untrusted comment: minisign public key\n" + base64.StdEncoding.EncodeToString(b) + "\n", priv
+}
+
+// testSign signs data like minisign -S, prehashed unless alg is minisignAlg
+func testSign(priv ed25519.PrivateKey, id, alg string, data []byte, comment string) string {
+	message := data
+	if alg == minisignAlgHashed {
+		hash := blake2b.Sum512(data)
+		message = hash[:]
+	}
+	sig := ed25519.Sign(priv, message)
+	global := ed25519.Sign(priv, append(append([]byte{}, sig...), comment...))
+	return "untrusted comment: signature from minisign secret key\n" +
+		base64.StdEncoding.EncodeToString(append([]byte(alg+id), sig...)) + "\n" +
+		trustedComment + comment + "\n" +
+		base64.StdEncoding.EncodeToString(global) + "\n"
+}
+
+func Test_minisignVerify(t *testing.T) {
+	const id = "12345678"
+	pub, priv := testKeyPair(t, id)
+	_, otherPriv := testKeyPair(t, id)
+	otherPub, _ := testKeyPair(t, "87654321")
+	data := []byte("goss binary")
+
+	tests := []struct {
+		name    string
+		pub     string
+		sig     string
+		wantErr string
+	}{
+		{
+			name: "prehashed",
+			pub:  pub,
+			sig:  testSign(priv, id, minisignAlgHashed, data, "timestamp:1700000000"),
+		},
+		{
+			name: "legacy",
+			pub:  strings.Split(pub, "\n")[1],
+			sig:  testSign(priv, id, minisignAlg, data, "timestamp:1700000000"),
+		},
+		{
+			name:    "tampered",
+			pub:     pub,
+			sig:     testSign(priv, id, minisignAlgHashed, []byte("other binary"), "timestamp:1700000000"),
+			wantErr: "invalid signature",
+		},
+		{
+			name:    "other key",
+			pub:     pub,
+			sig:     testSign(otherPriv, id, minisignAlgHashed, data, "timestamp:1700000000"),
+			wantErr: "invalid signature",
+		},
+		{
+			name:    "other key id",
+			pub:     otherPub,
+			sig:     testSign(priv, id, minisignAlgHashed, data, "timestamp:1700000000"),
+			wantErr: "signed with key",
+		},
+		{
+			name: "tampered trusted comment",
+			pub:  pub,
+			sig: strings.Replace(testSign(priv, id, minisignAlgHashed, data, "timestamp:1700000000"),
+				"timestamp:1700000000", "timestamp:1800000000", 1),
+			wantErr: "invalid trusted comment signature",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := parseMinisignKey(tt.pub)
+			if err != nil {
+				t.Fatalf("parseMinisignKey() error = %v", err)
+			}
+			sig, err := parseMinisignSig([]byte(tt.sig))
+			if err != nil {
+				t.Fatalf("parseMinisignSig() error = %v", err)
+			}
+			err = key.verify(data, sig)
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("minisignKey.verify() error = %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("minisignKey.verify() error = %v, want %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestProvisioner_installVerified(t *testing.T) {
+	const id = "12345678"
+	pub, priv := testKeyPair(t, id)
+	goss := []byte("goss binary")
+
+	tests := []struct {
+		name    string
+		sig     string
+		wantErr bool
+	}{
+		{
+			name: "verified",
+			sig:  testSign(priv, id, minisignAlgHashed, goss, "goss v0.4.2"),
+		},
+		{
+			name:    "tampered",
+			sig:     testSign(priv, id, minisignAlgHashed, []byte("other binary"), "goss v0.4.2"),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/goss-linux-amd64":
+					_, _ = w.Write(goss)
+				case "/goss-linux-amd64.minisig":
+					_, _ = w.Write([]byte(tt.sig))
+				default:
+					http.NotFound(w, r)
+				}
+			}))
+			defer server.Close()
+
+			p := &Provisioner{
+				config: GossConfig{
+					URL:                server.URL + "/goss-linux-amd64",
+					DownloadPath:       "/tmp/goss-0.4.2-linux-amd64",
+					SignaturePublicKey: pub,
+				},
+			}
+			comm := &packer.MockCommunicator{}
+			err := p.installVerified(packer.TestUi(t), comm)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Provisioner.installVerified() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				if comm.UploadCalled {
+					t.Errorf("unverified Goss uploaded")
+				}
+				return
+			}
+			if comm.UploadPath != p.config.DownloadPath || comm.UploadData != string(goss) {
+				t.Errorf("uploaded %q to %s, want %q to %s", comm.UploadData, comm.UploadPath, goss, p.config.DownloadPath)
+			}
+		})
+	}
+}
+
+func TestProvisioner_httpClientProxy(t *testing.T) {
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// A proxied request carries the absolute URL of the target
+		_, _ = w.Write([]byte("proxied " + r.URL.String()))
+	}))
+	defer proxy.Close()
+
+	p := &Provisioner{config: GossConfig{HTTPProxy: proxy.URL, NoProxy: "mirror.internal"}}
+	client, err := p.httpClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client.Timeout == 0 {
+		t.Errorf("client has no timeout")
+	}
+
+	got, err := p.fetch(client, "http://goss.example/goss-linux-amd64")
+	if err != nil {
+		t.Fatalf("Provisioner.fetch() error = %v", err)
+	}
+	if string(got) != "proxied http://goss.example/goss-linux-amd64" {
+		t.Errorf("Provisioner.fetch() = %q, want it proxied", got)
+	}
+
+	if u, _ := p.proxyConfig().ProxyFunc()(&url.URL{Scheme: "http", Host: "mirror.internal"}); u != nil {
+		t.Errorf("proxy for no_proxy host = %v, want none", u)
+	}
+}
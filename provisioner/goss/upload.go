@@ -0,0 +1,364 @@
+package goss
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// hasGlob reports whether a tests entry should be expanded as a glob pattern
+func hasGlob(pattern string) bool {
+	return strings.ContainsAny(pattern, "*?[")
+}
+
+// validGlob checks that every segment of pattern is a well formed path.Match pattern
+func validGlob(pattern string) error {
+	for _, segment := range strings.Split(filepath.ToSlash(pattern), "/") {
+		if _, err := path.Match(segment, ""); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// globRoot splits pattern into the longest leading directory without meta
+// characters and the remaining pattern segments.
+func globRoot(pattern string) (string, []string) {
+	segments := strings.Split(filepath.ToSlash(pattern), "/")
+
+	var rootSegments []string
+	for len(segments) > 1 && !hasGlob(segments[0]) {
+		rootSegments = append(rootSegments, segments[0])
+		segments = segments[1:]
+	}
+
+	root := strings.Join(rootSegments, "/")
+	if root == "" && strings.HasPrefix(filepath.ToSlash(pattern), "/") {
+		root = "/"
+	} else if root == "" {
+		root = "."
+	}
+	return filepath.FromSlash(root), segments
+}
+
+// expandGlob returns the regular files, and symlinks to them, matching pattern.
+// Besides the usual path.Match syntax a "**" segment matches any number of
+// directories. Symlinked directories aren't descended into.
+func expandGlob(pattern string) ([]string, error) {
+	if err := validGlob(pattern); err != nil {
+		return nil, err
+	}
+
+	root, segments := globRoot(pattern)
+
+	var matches []string
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			// Don't read directories the pattern can't match below, e.g. packer_cache for *.yaml
+			if rel != "." && !matchPrefix(segments, strings.Split(filepath.ToSlash(rel), "/")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !regularFile(p, d) {
+			return nil
+		}
+		if matchSegments(segments, strings.Split(filepath.ToSlash(rel), "/")) {
+			matches = append(matches, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return matches, nil
+}
+
+// regularFile reports whether the walked entry p is a regular file or a symlink to one.
+// Uploads follow the link, broken links are left to os.Stat in Prepare.
+func regularFile(p string, d fs.DirEntry) bool {
+	if d.Type()&fs.ModeSymlink != 0 {
+		info, err := os.Stat(p)
+		return err == nil && info.Mode().IsRegular()
+	}
+	return d.Type().IsRegular()
+}
+
+// matchPrefix reports whether paths below the directory name can match the pattern segments
+func matchPrefix(pattern, name []string) bool {
+	if len(name) == 0 {
+		return len(pattern) != 0
+	}
+	if len(pattern) == 0 {
+		return false
+	}
+	if pattern[0] == "**" {
+		return true
+	}
+	if ok, _ := path.Match(pattern[0], name[0]); !ok {
+		return false
+	}
+	return matchPrefix(pattern[1:], name[1:])
+}
+
+// matchSegments matches a slash separated path against pattern segments
+func matchSegments(pattern, name []string) bool {
+	if len(pattern) == 0 {
+		return len(name) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(name); i++ {
+			if matchSegments(pattern[1:], name[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(name) == 0 {
+		return false
+	}
+	if ok, _ := path.Match(pattern[0], name[0]); !ok {
+		return false
+	}
+	return matchSegments(pattern[1:], name[1:])
+}
+
+// excluded reports whether rel, a slash separated path relative to a tests
+// entry, matches one of the exclude patterns. Patterns without a slash are
+// matched against every element of the path so ".git" or "*.swp" work at any depth.
+func (p *Provisioner) excluded(rel string) bool {
+	elems := strings.Split(filepath.ToSlash(rel), "/")
+	for _, pattern := range p.config.Exclude {
+		pattern = strings.TrimSuffix(filepath.ToSlash(pattern), "/")
+		if strings.Contains(pattern, "/") {
+			if matchSegments(strings.Split(pattern, "/"), elems) {
+				return true
+			}
+			continue
+		}
+		for _, elem := range elems {
+			if ok, _ := path.Match(pattern, elem); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// resolveTests expands glob entries of the tests list and validates literal ones
+func (p *Provisioner) resolveTests() ([]string, error) {
+	var errs *packer.MultiError
+	var tests []string
+
+	for _, pattern := range p.config.Exclude {
+		if err := validGlob(pattern); err != nil {
+			errs = packer.MultiErrorAppend(errs,
+				fmt.Errorf("Bad exclude pattern '%s': %s", pattern, err))
+		}
+	}
+
+	for _, entry := range p.config.Tests {
+		if !hasGlob(entry) {
+			tests = append(tests, entry)
+			continue
+		}
+
+		matches, err := expandGlob(entry)
+		if err != nil {
+			errs = packer.MultiErrorAppend(errs,
+				fmt.Errorf("Bad test pattern '%s': %s", entry, err))
+			continue
+		}
+
+		root, _ := globRoot(entry)
+		count := 0
+		for _, match := range matches {
+			rel, err := filepath.Rel(root, match)
+			if err == nil && p.excluded(rel) {
+				continue
+			}
+			tests = append(tests, match)
+			count++
+		}
+		if count == 0 {
+			errs = packer.MultiErrorAppend(errs,
+				fmt.Errorf("Test pattern '%s' did not match any files or symlinks to files", entry))
+		}
+	}
+
+	if errs != nil && len(errs.Errors) > 0 {
+		return nil, errs
+	}
+	return tests, nil
+}
+
+// uploadDirFiltered uploads a directory file by file, skipping excluded paths
+func (p *Provisioner) uploadDirFiltered(ui packer.Ui, comm packer.Communicator, dst, src string) error {
+	return filepath.WalkDir(src, func(local string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, local)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			return p.createDir(ui, comm, dst)
+		}
+
+		if p.excluded(rel) {
+			ui.Message(fmt.Sprintf("Excluding %s", local))
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		remote := path.Join(dst, filepath.ToSlash(rel))
+		switch {
+		case d.IsDir():
+			return p.createDir(ui, comm, remote)
+		case regularFile(local, d):
+			return p.uploadFile(ui, comm, remote, local)
+		default:
+			return nil
+		}
+	})
+}
+
+// remoteTestPath returns the remote path a local test file or directory is uploaded to
+func (p *Provisioner) remoteTestPath(src string) (string, error) {
+	if !p.config.PreservePaths {
+		return filepath.ToSlash(filepath.Join(p.config.RemotePath, filepath.Base(src))), nil
+	}
+
+	base, err := filepath.Abs(p.config.BaseDir)
+	if err != nil {
+		return "", err
+	}
+	abs, err := filepath.Abs(src)
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(base, abs)
+	if err != nil {
+		return "", err
+	}
+	rel = filepath.ToSlash(rel)
+	if rel == ".." || strings.HasPrefix(rel, "../") {
+		return "", fmt.Errorf("'%s' is outside of base_dir '%s'", src, p.config.BaseDir)
+	}
+	return path.Join(filepath.ToSlash(p.config.RemotePath), rel), nil
+}
+
+// upload maps a local file to the remote path it is uploaded to
+type upload struct {
+	src string
+	dst string
+}
+
+// uploadPlan lists every file uploaded for the vars file and the tests,
+// with directories expanded and excluded paths left out.
+func (p *Provisioner) uploadPlan() ([]upload, error) {
+	var plan []upload
+
+	entries := p.config.Tests
+	if p.config.VarsFile != "" {
+		entries = append([]string{p.config.VarsFile}, entries...)
+	}
+
+	for _, entry := range entries {
+		s, err := os.Stat(entry)
+		if err != nil {
+			return nil, err
+		}
+		if p.excluded(filepath.Base(entry)) {
+			continue
+		}
+		dst, err := p.remoteTestPath(entry)
+		if err != nil {
+			return nil, err
+		}
+
+		if s.Mode().IsRegular() {
+			plan = append(plan, upload{src: entry, dst: dst})
+		} else if s.Mode().IsDir() {
+			err := filepath.WalkDir(entry, func(local string, d fs.DirEntry, err error) error {
+				if err != nil {
+					return err
+				}
+				rel, err := filepath.Rel(entry, local)
+				if err != nil {
+					return err
+				}
+				if rel != "." && p.excluded(rel) {
+					if d.IsDir() {
+						return filepath.SkipDir
+					}
+					return nil
+				}
+				if regularFile(local, d) {
+					plan = append(plan, upload{src: local, dst: path.Join(dst, filepath.ToSlash(rel))})
+				}
+				return nil
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return plan, nil
+}
+
+// checkDestinations makes sure no two local files are uploaded to the same remote path
+func (p *Provisioner) checkDestinations() error {
+	plan, err := p.uploadPlan()
+	if err != nil {
+		return err
+	}
+
+	var errs *packer.MultiError
+	sources := make(map[string]string)
+	for _, u := range plan {
+		src := u.src
+		if abs, err := filepath.Abs(src); err == nil {
+			src = abs
+		}
+		if other, ok := sources[u.dst]; ok && other != src {
+			errs = packer.MultiErrorAppend(errs,
+				fmt.Errorf("'%s' and '%s' would both be uploaded to '%s'", other, src, u.dst))
+			continue
+		}
+		sources[u.dst] = src
+	}
+
+	if errs != nil && len(errs.Errors) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// uploadTestFile uploads a single test file, creating its remote parent
+// directory first when the local directory structure is preserved.
+func (p *Provisioner) uploadTestFile(ui packer.Ui, comm packer.Communicator, dst, src string) error {
+	if p.config.PreservePaths {
+		if dir := path.Dir(dst); dir != filepath.ToSlash(p.config.RemotePath) {
+			if err := p.createDir(ui, comm, dir); err != nil {
+				return err
+			}
+		}
+	}
+	return p.uploadFile(ui, comm, dst, src)
+}
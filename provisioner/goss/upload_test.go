@@ -0,0 +1,325 @@
+package goss
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+func writeTree(t *testing.T, files ...string) string {
+	t.Helper()
+	root := t.TempDir()
+	for _, file := range files {
+		path := filepath.Join(root, filepath.FromSlash(file))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("{}\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestExpandGlob(t *testing.T) {
+	root := writeTree(t,
+		"goss.yaml",
+		"README.md",
+		"services/web/goss.yaml",
+		"services/db/goss.yml",
+		"services/db/goss.yaml",
+	)
+
+	if err := os.Symlink(filepath.Join(root, "services/web/goss.yaml"), filepath.Join(root, "linked.yaml")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(root, "missing.yaml"), filepath.Join(root, "broken.yaml")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		pattern string
+		want    []string
+	}{
+		{
+			name:    "single level",
+			pattern: "*.yaml",
+			want:    []string{"goss.yaml", "linked.yaml"},
+		},
+		{
+			name:    "double star",
+			pattern: "**/*.yaml",
+			want:    []string{"goss.yaml", "linked.yaml", "services/db/goss.yaml", "services/web/goss.yaml"},
+		},
+		{
+			name:    "double star in the middle",
+			pattern: "services/**/goss.y?l",
+			want:    []string{"services/db/goss.yml"},
+		},
+		{
+			name:    "no match",
+			pattern: "**/*.json",
+			want:    nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := expandGlob(filepath.Join(root, tt.pattern))
+			if err != nil {
+				t.Fatalf("expandGlob() error = %v", err)
+			}
+			var rels []string
+			for _, match := range got {
+				rel, _ := filepath.Rel(root, match)
+				rels = append(rels, filepath.ToSlash(rel))
+			}
+			if !reflect.DeepEqual(rels, tt.want) {
+				t.Errorf("expandGlob() = %v, want %v", rels, tt.want)
+			}
+		})
+	}
+}
+
+func Test_matchPrefix(t *testing.T) {
+	tests := []struct {
+		pattern string
+		dir     string
+		want    bool
+	}{
+		{pattern: "*.yaml", dir: "packer_cache", want: false},
+		{pattern: "services/*/goss.yaml", dir: "services", want: true},
+		{pattern: "services/*/goss.yaml", dir: "services/web", want: true},
+		{pattern: "services/*/goss.yaml", dir: "output", want: false},
+		{pattern: "services/**/goss.yaml", dir: "services/web/nested", want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern+" "+tt.dir, func(t *testing.T) {
+			if got := matchPrefix(strings.Split(tt.pattern, "/"), strings.Split(tt.dir, "/")); got != tt.want {
+				t.Errorf("matchPrefix() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProvisioner_uploadDirFiltered(t *testing.T) {
+	root := writeTree(t, "tests/goss.yaml", "tests/.git/config", "shared/nginx.yaml")
+	if err := os.Symlink(filepath.Join(root, "shared/nginx.yaml"), filepath.Join(root, "tests/nginx.yaml")); err != nil {
+		t.Fatal(err)
+	}
+
+	p := &Provisioner{config: GossConfig{Exclude: []string{".git"}}}
+	plan := p.newDryRunPlan(packer.TestUi(t))
+	if err := p.uploadDirFiltered(packer.TestUi(t), plan, "/tmp/goss/tests", filepath.Join(root, "tests")); err != nil {
+		t.Fatalf("Provisioner.uploadDirFiltered() error = %v", err)
+	}
+
+	var got []string
+	for _, step := range plan.Steps {
+		if step.Action == "upload" {
+			got = append(got, step.Destination)
+		}
+	}
+	want := []string{"/tmp/goss/tests/goss.yaml", "/tmp/goss/tests/nginx.yaml"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("uploaded %v, want %v", got, want)
+	}
+}
+
+func TestProvisioner_excluded(t *testing.T) {
+	p := &Provisioner{
+		config: GossConfig{
+			Exclude: []string{".git", "*.swp", "fixtures/**", "README.md"},
+		},
+	}
+
+	tests := []struct {
+		rel  string
+		want bool
+	}{
+		{rel: "goss.yaml", want: false},
+		{rel: ".git", want: true},
+		{rel: "sub/.git/config", want: true},
+		{rel: "sub/.goss.yaml.swp", want: true},
+		{rel: "fixtures/data.json", want: true},
+		{rel: "sub/fixtures/data.json", want: false},
+		{rel: "README.md", want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.rel, func(t *testing.T) {
+			if got := p.excluded(tt.rel); got != tt.want {
+				t.Errorf("Provisioner.excluded(%q) = %v, want %v", tt.rel, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProvisioner_PrepareGlob(t *testing.T) {
+	root := writeTree(t,
+		"goss/goss.yaml",
+		"goss/nested/service.yaml",
+		"goss/fixtures/fixture.yaml",
+	)
+
+	tests := []struct {
+		name      string
+		input     map[string]interface{}
+		wantErr   bool
+		wantTests []string
+	}{
+		{
+			name: "glob with exclude",
+			input: map[string]interface{}{
+				"tests":   []string{filepath.Join(root, "goss/**/*.yaml")},
+				"exclude": []string{"fixtures"},
+			},
+			wantTests: []string{
+				filepath.Join(root, "goss/goss.yaml"),
+				filepath.Join(root, "goss/nested/service.yaml"),
+			},
+		},
+		{
+			name: "glob without match",
+			input: map[string]interface{}{
+				"tests": []string{filepath.Join(root, "goss/**/*.json")},
+			},
+			wantErr: true,
+		},
+		{
+			name: "bad exclude pattern",
+			input: map[string]interface{}{
+				"tests":   []string{filepath.Join(root, "goss")},
+				"exclude": []string{"[a-"},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &Provisioner{}
+			err := p.Prepare(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Provisioner.Prepare() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && !reflect.DeepEqual(p.config.Tests, tt.wantTests) {
+				t.Errorf("Provisioner.Prepare() tests = %v, want %v", p.config.Tests, tt.wantTests)
+			}
+		})
+	}
+}
+
+func TestProvisioner_remoteTestPath(t *testing.T) {
+	root := writeTree(t, "goss/web/goss.yaml")
+
+	tests := []struct {
+		name    string
+		config  GossConfig
+		src     string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:   "base name",
+			config: GossConfig{RemotePath: "/tmp/goss"},
+			src:    filepath.Join(root, "goss/web/goss.yaml"),
+			want:   "/tmp/goss/goss.yaml",
+		},
+		{
+			name:   "preserve paths",
+			config: GossConfig{RemotePath: "/tmp/goss", PreservePaths: true, BaseDir: root},
+			src:    filepath.Join(root, "goss/web/goss.yaml"),
+			want:   "/tmp/goss/goss/web/goss.yaml",
+		},
+		{
+			name:    "outside of base dir",
+			config:  GossConfig{RemotePath: "/tmp/goss", PreservePaths: true, BaseDir: filepath.Join(root, "goss/web")},
+			src:     filepath.Join(root, "goss"),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &Provisioner{
+				config: tt.config,
+			}
+			got, err := p.remoteTestPath(tt.src)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Provisioner.remoteTestPath() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Provisioner.remoteTestPath() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProvisioner_checkDestinations(t *testing.T) {
+	root := writeTree(t,
+		"web/goss.yaml",
+		"db/goss.yaml",
+		"dirs/a/common/goss.yaml",
+		"dirs/b/common/goss.yaml",
+		"dirs/b/common/other.yaml",
+	)
+
+	tests := []struct {
+		name    string
+		config  GossConfig
+		wantErr bool
+	}{
+		{
+			name: "same base name",
+			config: GossConfig{
+				Tests: []string{filepath.Join(root, "web/goss.yaml"), filepath.Join(root, "db/goss.yaml")},
+			},
+			wantErr: true,
+		},
+		{
+			name: "same base name with preserved paths",
+			config: GossConfig{
+				Tests:         []string{filepath.Join(root, "web/goss.yaml"), filepath.Join(root, "db/goss.yaml")},
+				PreservePaths: true,
+				BaseDir:       root,
+			},
+			wantErr: false,
+		},
+		{
+			name: "directories merging",
+			config: GossConfig{
+				Tests: []string{filepath.Join(root, "dirs/a/common"), filepath.Join(root, "dirs/b/common")},
+			},
+			wantErr: true,
+		},
+		{
+			name: "colliding file excluded",
+			config: GossConfig{
+				Tests:   []string{filepath.Join(root, "dirs/a/common"), filepath.Join(root, "dirs/b/common")},
+				Exclude: []string{"goss.yaml"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "vars file also listed in tests",
+			config: GossConfig{
+				Tests:    []string{filepath.Join(root, "web/goss.yaml")},
+				VarsFile: filepath.Join(root, "web/goss.yaml"),
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.config.RemotePath = "/tmp/goss"
+			p := &Provisioner{
+				config: tt.config,
+			}
+			if err := p.checkDestinations(); (err != nil) != tt.wantErr {
+				t.Errorf("Provisioner.checkDestinations() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}